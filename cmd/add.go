@@ -52,6 +52,7 @@ var addCmd = &cobra.Command{
 			logrus.WithField("uri", repo.Repo).Fatal("Unable to parse repository URI")
 		}
 		manifest.Save(root)
+		indexRepos(root, repo)
 	},
 }
 
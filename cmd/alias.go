@@ -0,0 +1,137 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/utils"
+	"github.com/spf13/cobra"
+)
+
+// aliasSetup finds the Jig root and loads its manifest
+func aliasSetup() (string, *config.Manifest) {
+	root, err := config.FindClosestJigRoot("")
+	if err != nil {
+		logrus.Fatal("No jig root found. Use 'jig init' to create one.")
+	}
+	manifest, err := config.DefaultManifest(root)
+	if err != nil {
+		logrus.Fatal("No repo manifest to use. `jig restore` a manifest first.")
+	}
+	return root, manifest
+}
+
+// aliasCmd represents the alias command
+var aliasCmd = &cobra.Command{
+	Use:   "alias",
+	Short: "Give repositories short names",
+	Long: `Give repositories short names to select them by. An alias is accepted anywhere
+a repository can be named, and a search in ls or cdj that's exactly an alias
+goes to its repository before anything else. Run without a subcommand to list
+the aliases.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, manifest := aliasSetup()
+		type alias struct{ name, repo string }
+		aliases := []alias{}
+		for _, r := range manifest.Repos {
+			short, err := utils.RepoToPath(r.Repo)
+			if err != nil {
+				short = r.Repo
+			}
+			for _, name := range r.Aliases {
+				aliases = append(aliases, alias{name, short})
+			}
+		}
+		sort.Slice(aliases, func(i, j int) bool { return aliases[i].name < aliases[j].name })
+		w := tabwriter.NewWriter(os.Stdout, 0, 5, 4, ' ', 0)
+		fmt.Fprintln(w, "Alias\tRepo")
+		for _, a := range aliases {
+			fmt.Fprintf(w, "%s\t%s\n", a.name, a.repo)
+		}
+		w.Flush()
+	},
+}
+
+var aliasAddCmd = &cobra.Command{
+	Use:   "add <name> <repo>",
+	Short: "Give a repository an alias",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			logrus.Fatal("Must pass an alias and the repository it names")
+		}
+		name := args[0]
+		if name == "" || strings.ContainsAny(name, "/ \t") {
+			logrus.WithField("alias", name).Fatal("Aliases can't contain slashes or spaces")
+		}
+		root, manifest := aliasSetup()
+		selected := selectRepos(manifest, args[1:])
+		if len(selected) > 1 {
+			logrus.WithField("repo", args[1]).Fatal("More than one repository matches; name it more fully")
+		}
+		repo := selected[0]
+		if other := manifest.Alias(name); other != nil {
+			if other == repo {
+				return
+			}
+			logrus.WithFields(logrus.Fields{
+				"alias": name,
+				"repo":  other.Repo,
+			}).Fatal("Alias is already in use")
+		}
+		repo.Aliases = append(repo.Aliases, name)
+		if err := manifest.Save(root); err != nil {
+			logrus.WithError(err).Fatal("Unable to save manifest")
+		}
+	},
+}
+
+var aliasRmCmd = &cobra.Command{
+	Use:   "rm <name>...",
+	Short: "Remove aliases",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			logrus.Fatal("Must pass the aliases to remove")
+		}
+		root, manifest := aliasSetup()
+		for _, name := range args {
+			repo := manifest.Alias(name)
+			if repo == nil {
+				logrus.WithField("alias", name).Fatal("No such alias")
+			}
+			kept := []string{}
+			for _, alias := range repo.Aliases {
+				if alias != name {
+					kept = append(kept, alias)
+				}
+			}
+			repo.Aliases = kept
+		}
+		if err := manifest.Save(root); err != nil {
+			logrus.WithError(err).Fatal("Unable to save manifest")
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(aliasCmd)
+	aliasCmd.AddCommand(aliasAddCmd, aliasRmCmd)
+}
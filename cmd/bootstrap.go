@@ -15,40 +15,91 @@
 package cmd
 
 import (
-	"fmt"
+	"os"
+	"regexp"
+	"text/template"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var (
 	alias   = "cdj"
 	jigroot = ""
+	shell   = ""
 )
 
-var bootstrap = `
-%s() {
-	CDDIR="$@"
-	if [ -z "$CDDIR" ]; then
-		cd $(%s jig root)
+// bootstraps define the cd function in each shell
+var bootstraps = map[string]*template.Template{
+	"bash": posixBootstrap,
+	"zsh":  posixBootstrap,
+	"fish": template.Must(template.New("fish").Parse(`
+function {{.Name}}
+	if test (count $argv) -eq 0
+		cd ({{.Env}}jig root)
 	else
-		DESTDIR=$(%s jig ls -n1 $CDDIR)
-		if [ -z "$DESTDIR" ]; then
+		set -l dest ({{.Env}}jig ls -n1 $argv)
+		if test -z "$dest"
+			return 1
+		end
+		cd $dest
+	end
+end
+`)),
+}
+
+var posixBootstrap = template.Must(template.New("posix").Parse(`
+{{.Name}}() {
+	if [ $# -eq 0 ]; then
+		cd "$({{.Env}}jig root)"
+	else
+		local dest
+		dest=$({{.Env}}jig ls -n1 "$@")
+		if [ -z "$dest" ]; then
 			return 1
 		fi
-		cd $DESTDIR
+		cd "$dest"
 	fi
 }
-`
+`))
+
+// functionName is what the cd function can be called in every shell
+var functionName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
 
 // bootstrapCmd represents the bootstrap command
 var bootstrapCmd = &cobra.Command{
 	Use:   "bootstrap",
 	Short: "Install jig tools into your shell",
+	Long: `Print a function that changes to the repository best matching its arguments,
+along with completion for it and for jig, for bash, zsh or fish. The shell is
+guessed from $SHELL unless --shell says otherwise. Load it in your shell's
+startup file, for example:
+
+    eval "$(jig bootstrap --shell bash)"
+    eval "$(jig bootstrap --shell zsh)"
+    jig bootstrap --shell fish | source`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if jigroot != "" {
-			jigroot = fmt.Sprintf("JIGROOT=%s", jigroot)
+		if shell == "" {
+			shell = defaultShell()
+		}
+		validShell(shell)
+		if !functionName.MatchString(alias) {
+			logrus.WithField("name", alias).Fatal("Not a name a shell function can have")
+		}
+		fn := newScript(shell, alias, jigroot)
+		if err := bootstraps[shell].Execute(os.Stdout, fn); err != nil {
+			logrus.WithError(err).Fatal("Unable to write bootstrap")
+		}
+		// The function completes like jig ls
+		fn.Prefix = "ls "
+		if err := completionScripts[shell].Execute(os.Stdout, fn); err != nil {
+			logrus.WithError(err).Fatal("Unable to write bootstrap")
+		}
+		jig := newScript(shell, "jig", "")
+		jig.Files = true
+		if err := completionScripts[shell].Execute(os.Stdout, jig); err != nil {
+			logrus.WithError(err).Fatal("Unable to write bootstrap")
 		}
-		fmt.Printf(bootstrap, alias, jigroot, jigroot)
 	},
 }
 
@@ -56,4 +107,5 @@ func init() {
 	RootCmd.AddCommand(bootstrapCmd)
 	bootstrapCmd.Flags().StringVarP(&alias, "cd-command", "c", "cdj", "The command name to use for changing dirs")
 	bootstrapCmd.Flags().StringVarP(&jigroot, "with-jigroot", "j", "", "Use a custom jig root for this evaluation")
+	bootstrapCmd.Flags().StringVar(&shell, "shell", "", "The shell to write for: bash, zsh or fish (default is from $SHELL)")
 }
@@ -0,0 +1,348 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/utils"
+	"github.com/iancmcc/jig/vcs"
+	"github.com/spf13/cobra"
+)
+
+// A bundle archive is a tar file holding the manifest, a description of the
+// bundle, and a git bundle for each repository, named by the path the
+// repository is checked out to.
+const (
+	bundleManifestName = "manifest.json"
+	bundleInfoName     = "bundle.json"
+	bundleReposDir     = "repos"
+	bundleVersion      = 1
+)
+
+var (
+	since      string
+	fromBundle string
+)
+
+// bundleInfo describes a bundle archive
+type bundleInfo struct {
+	Version int
+	Created time.Time
+	// Since is the archive this one is relative to, if any
+	Since string `json:",omitempty"`
+	// Repos holds the refs of each repository when the archive was created.
+	// Repositories that hadn't changed since the previous archive have no
+	// bundle of their own.
+	Repos map[string]map[string]string
+	// RefsOnly lists the repositories whose refs changed without any new
+	// history. They have no bundle either, but restoring sets their refs.
+	RefsOnly []string `json:",omitempty"`
+}
+
+// bundlePath returns where the bundle for a repository is kept in an
+// extracted archive
+func bundlePath(dir, short string) string {
+	return filepath.Join(dir, bundleReposDir, filepath.FromSlash(short)+".bundle")
+}
+
+// extractBundle unpacks a bundle archive into a temporary directory, which
+// the caller should remove
+func extractBundle(archive string) (string, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	dir, err := ioutil.TempDir("", "jig-bundle-")
+	if err != nil {
+		return "", err
+	}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if hdr.Typeflag != tar.TypeReg || filepath.IsAbs(name) || strings.HasPrefix(name, "..") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0775); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+		out, err := os.Create(path)
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+		_, err = io.Copy(out, tr)
+		out.Close()
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// readBundleInfo reads the description of an archive without extracting it
+func readBundleInfo(archive string) (*bundleInfo, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s is not a jig bundle", archive)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Name != bundleInfoName {
+			continue
+		}
+		var info bundleInfo
+		if err := json.NewDecoder(tr).Decode(&info); err != nil {
+			return nil, err
+		}
+		return &info, nil
+	}
+}
+
+// writeBundle writes an archive from the bundles in dir
+func writeBundle(archive, dir string, manifest *config.Manifest, info *bundleInfo) error {
+	tmp := archive + "~"
+	defer os.Remove(tmp)
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	add := func(name string, size int64, r io.Reader) error {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    size,
+			ModTime: info.Created,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := io.Copy(tw, r)
+		return err
+	}
+	var buf strings.Builder
+	if err := manifest.ToJSON(&buf); err != nil {
+		return err
+	}
+	if err := add(bundleManifestName, int64(buf.Len()), strings.NewReader(buf.String())); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := add(bundleInfoName, int64(len(data)), strings.NewReader(string(data))); err != nil {
+		return err
+	}
+	for short := range info.Repos {
+		path := bundlePath(dir, short)
+		stat, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		b, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = add(bundleReposDir+"/"+short+".bundle", stat.Size(), b)
+		b.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, archive)
+}
+
+// restoreFromBundle restores a repository from an extracted archive. A
+// repository the archive has no bundle for is left alone if it's already
+// checked out, apart from setting any refs that changed.
+func restoreFromBundle(root, dir string, info *bundleInfo, repo *config.Repo) (<-chan vcs.Progress, error) {
+	short, err := utils.RepoToPath(repo.Repo)
+	if err != nil {
+		return nil, err
+	}
+	path := bundlePath(dir, short)
+	if _, err := os.Stat(path); err != nil {
+		if _, err := os.Stat(filepath.Join(root, short)); err != nil {
+			return nil, fmt.Errorf("the bundle has nothing for %s; restore the bundle it was made since first", short)
+		}
+		for _, s := range info.RefsOnly {
+			if s == short {
+				return vcs.Git.RestoreBundle(repo, filepath.Join(root, short), "", info.Repos[short])
+			}
+		}
+		done := make(chan vcs.Progress)
+		close(done)
+		return done, nil
+	}
+	return vcs.Git.RestoreBundle(repo, filepath.Join(root, short), path, nil)
+}
+
+// bundleCmd represents the bundle command
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Move repositories between machines without a network",
+	Long: `Bundles carry the manifest and the history of every repository in a single
+archive, for restoring where the remotes can't be reached. See 'jig bundle
+create' and 'jig restore --from-bundle'.`,
+}
+
+var bundleCreateCmd = &cobra.Command{
+	Use:   "create <archive> [repo...]",
+	Short: "Write the manifest and a git bundle per repository to an archive",
+	Long: `Write the manifest and a git bundle of the branches, tags and remote-tracking
+branches of every repository in the manifest, or just those named, to a tar
+archive. With --since, the bundles leave out history already in a previous
+archive. Repositories that haven't changed are left out altogether, and
+those whose refs changed without new history carry just their refs; restore
+the previous archive before this one.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			logrus.Fatal("Must pass the archive to write")
+		}
+		archive := args[0]
+		root, err := config.FindClosestJigRoot("")
+		if err != nil {
+			logrus.Fatal("No jig root found. Use 'jig init' to create one.")
+		}
+		manifest, err := config.DefaultManifest("")
+		if err != nil {
+			logrus.Fatal("No repo manifest to bundle. `jig restore` a manifest first.")
+		}
+		info := &bundleInfo{
+			Version: bundleVersion,
+			Created: time.Now().UTC(),
+			Repos:   map[string]map[string]string{},
+		}
+		var prev *bundleInfo
+		if since != "" {
+			if prev, err = readBundleInfo(since); err != nil {
+				logrus.WithError(err).Fatal("Unable to read previous bundle")
+			}
+			info.Since = filepath.Base(since)
+		}
+		dir, err := ioutil.TempDir("", "jig-bundle-")
+		if err != nil {
+			logrus.WithError(err).Fatal("Unable to create temporary directory")
+		}
+		defer os.RemoveAll(dir)
+
+		repos := []*config.Repo{}
+		tasks := []vcs.Task{}
+		bundled := []string{}
+		for _, repo := range selectRepos(manifest, args[1:]) {
+			log := logrus.WithField("repo", repo.Repo)
+			short, err := utils.RepoToPath(repo.Repo)
+			if err != nil {
+				log.Error("Unable to parse repo")
+				continue
+			}
+			repodir := filepath.Join(root, short)
+			if _, err := os.Stat(repodir); err != nil {
+				log.Warn("Skipping repo that hasn't been cloned")
+				continue
+			}
+			refs, err := vcs.Git.Refs(repo, repodir)
+			if err != nil {
+				log.WithError(err).Error("Unable to list refs")
+				continue
+			}
+			repos = append(repos, repo)
+			info.Repos[short] = refs
+			var exclude []string
+			if prev != nil {
+				if reflect.DeepEqual(prev.Repos[short], refs) {
+					continue
+				}
+				for _, sha := range prev.Repos[short] {
+					exclude = append(exclude, sha)
+				}
+			}
+			bundlechan, err := vcs.Git.CreateBundle(repo, repodir, bundlePath(dir, short), exclude)
+			if err == vcs.ErrEmptyBundle {
+				info.RefsOnly = append(info.RefsOnly, short)
+				continue
+			}
+			if err != nil {
+				log.WithError(err).Error("Unable to bundle repo")
+				delete(info.Repos, short)
+				continue
+			}
+			tasks = append(tasks, vcs.NewTask(repo.Repo, bundlechan))
+			bundled = append(bundled, short)
+		}
+		showProgress(tasks...)
+		for _, short := range bundled {
+			if _, err := os.Stat(bundlePath(dir, short)); err != nil {
+				delete(info.Repos, short)
+			}
+		}
+		// Leave out repositories that failed to bundle
+		kept := &config.Manifest{Repos: []*config.Repo{}}
+		for _, repo := range repos {
+			if short, _ := utils.RepoToPath(repo.Repo); info.Repos[short] != nil {
+				kept.Repos = append(kept.Repos, repo)
+			}
+		}
+		if err := writeBundle(archive, dir, kept, info); err != nil {
+			logrus.WithError(err).Fatal("Unable to write bundle")
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(bundleCmd)
+	bundleCmd.AddCommand(bundleCreateCmd)
+	bundleCreateCmd.Flags().StringVar(&since, "since", "", "Only bundle what has changed since a previous bundle")
+}
@@ -0,0 +1,195 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/utils"
+	"github.com/iancmcc/jig/vcs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cacheDir   string
+	cacheLocal bool
+	pruneAll   bool
+)
+
+// cacheSetup finds the Jig root and its object cache, failing if caching
+// isn't enabled
+func cacheSetup() (string, *vcs.ObjectCache) {
+	root, err := config.FindClosestJigRoot("")
+	if err != nil {
+		logrus.Fatal("No jig root found. Use 'jig init' to create one.")
+	}
+	settings, err := config.DefaultSettings(root)
+	if err != nil {
+		logrus.WithError(err).Fatal("Unable to load jig settings")
+	}
+	cache, err := objectCache(root, settings)
+	if err != nil {
+		logrus.WithError(err).Fatal("Unable to locate object cache")
+	}
+	if cache == nil {
+		logrus.Fatal("The object cache is disabled. Use 'jig cache enable' to turn it on.")
+	}
+	return root, cache
+}
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect and maintain the shared object cache",
+	Long: `Jig can keep a bare mirror of every repository in an object cache. Clones
+borrow objects from the cache instead of downloading full histories, and pulls
+keep it up to date. Run without a subcommand to list the cached mirrors.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, cache := cacheSetup()
+		mirrors, err := cache.Mirrors()
+		if err != nil {
+			logrus.WithError(err).Fatal("Unable to read object cache")
+		}
+		var total int64
+		w := tabwriter.NewWriter(os.Stdout, 0, 5, 4, ' ', 0)
+		fmt.Fprintf(w, "Repo\tSize\tUpdated\n")
+		for _, m := range mirrors {
+			total += m.Size
+			fmt.Fprintf(w, "%s\t%s\t%s\n", m.Repo, utils.HumanBytes(m.Size), m.Updated.Format("2006-01-02 15:04"))
+		}
+		w.Flush()
+		fmt.Printf("\n%d mirrors, %s in %s\n", len(mirrors), utils.HumanBytes(total), cache.Dir)
+	},
+}
+
+var cacheEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Use the object cache for clones and pulls in this Jig root",
+	Run: func(cmd *cobra.Command, args []string) {
+		root, err := config.FindClosestJigRoot("")
+		if err != nil {
+			logrus.Fatal("No jig root found. Use 'jig init' to create one.")
+		}
+		settings, err := config.DefaultSettings(root)
+		if err != nil {
+			logrus.WithError(err).Fatal("Unable to load jig settings")
+		}
+		settings.Cache.Enabled = true
+		settings.Cache.Dir = ""
+		if cacheDir != "" {
+			if settings.Cache.Dir, err = filepath.Abs(cacheDir); err != nil {
+				logrus.WithError(err).Fatal("Unable to resolve cache directory")
+			}
+		}
+		if cacheLocal {
+			settings.Cache.Dir = config.LocalCacheDir
+		}
+		if err := settings.Save(root); err != nil {
+			logrus.WithError(err).Fatal("Unable to save jig settings")
+		}
+	},
+}
+
+var cacheDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Stop using the object cache in this Jig root",
+	Run: func(cmd *cobra.Command, args []string) {
+		root, err := config.FindClosestJigRoot("")
+		if err != nil {
+			logrus.Fatal("No jig root found. Use 'jig init' to create one.")
+		}
+		settings, err := config.DefaultSettings(root)
+		if err != nil {
+			logrus.WithError(err).Fatal("Unable to load jig settings")
+		}
+		settings.Cache.Enabled = false
+		if err := settings.Save(root); err != nil {
+			logrus.WithError(err).Fatal("Unable to save jig settings")
+		}
+	},
+}
+
+var cacheRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Create or update cached mirrors for every repository in the manifest",
+	Run: func(cmd *cobra.Command, args []string) {
+		root, cache := cacheSetup()
+		applySettings(root)
+		manifest, err := config.DefaultManifest("")
+		if err != nil {
+			logrus.Fatal("No repo manifest to refresh from. `jig restore` a manifest first.")
+		}
+		tasks := []vcs.Task{}
+		for _, repo := range manifest.Repos {
+			refreshchan, err := cache.Refresh(repo.Repo, vcs.Git.URL(repo))
+			if err != nil {
+				logrus.WithError(err).WithField("repo", repo.Repo).Error("Unable to refresh cached mirror")
+				continue
+			}
+			tasks = append(tasks, vcs.NewTask(repo.Repo, refreshchan))
+		}
+		showProgress(tasks...)
+	},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove cached mirrors of repositories no longer in the manifest",
+	Run: func(cmd *cobra.Command, args []string) {
+		_, cache := cacheSetup()
+		keep := map[string]struct{}{}
+		if !pruneAll {
+			manifest, err := config.DefaultManifest("")
+			if err != nil {
+				logrus.Fatal("No repo manifest to prune against. Pass --all to empty the cache.")
+			}
+			for _, repo := range manifest.Repos {
+				if short, err := utils.RepoToPath(repo.Repo); err == nil {
+					keep[short] = struct{}{}
+				}
+			}
+		}
+		mirrors, err := cache.Mirrors()
+		if err != nil {
+			logrus.WithError(err).Fatal("Unable to read object cache")
+		}
+		var freed int64
+		for _, m := range mirrors {
+			if _, ok := keep[m.Repo]; ok {
+				continue
+			}
+			if err := cache.Remove(m); err != nil {
+				logrus.WithError(err).WithField("repo", m.Repo).Error("Unable to remove cached mirror")
+				continue
+			}
+			freed += m.Size
+			fmt.Printf("Removed %s\n", m.Repo)
+		}
+		fmt.Printf("Freed %s\n", utils.HumanBytes(freed))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheEnableCmd, cacheDisableCmd, cacheRefreshCmd, cachePruneCmd)
+	cacheEnableCmd.Flags().StringVarP(&cacheDir, "dir", "d", "", "Directory to keep the cache in (default is the user cache directory)")
+	cacheEnableCmd.Flags().BoolVarP(&cacheLocal, "local", "l", false, "Keep the cache inside this Jig root")
+	cachePruneCmd.Flags().BoolVarP(&pruneAll, "all", "a", false, "Remove every cached mirror")
+}
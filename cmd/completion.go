@@ -0,0 +1,325 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/fs"
+	"github.com/iancmcc/jig/match"
+	"github.com/iancmcc/jig/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// shells are the shells jig can write functions and completion for
+var shells = []string{"bash", "zsh", "fish"}
+
+// completionScripts ask jig what can come next on the command line. Prefix
+// goes before the words typed, so cdj can complete like jig ls.
+var completionScripts = map[string]*template.Template{
+	"bash": template.Must(template.New("bash").Parse(`
+_{{.Func}}_complete() {
+	local IFS=$'\n'
+	COMPREPLY=($({{.Env}}jig __complete -- {{.Prefix}}"${COMP_WORDS[@]:1:COMP_CWORD}"))
+}
+complete {{if .Files}}-o default {{end}}-F _{{.Func}}_complete {{.Name}}
+`)),
+	"zsh": template.Must(template.New("zsh").Parse(`
+_{{.Func}}_complete() {
+	local -a completions
+	completions=(${(f)"$({{.Env}}jig __complete -- {{.Prefix}}"${(@)words[2,CURRENT]}")"})
+	{{if .Files}}(( ${#completions} )) || _files
+	{{end}}compadd -a completions
+}
+if (( $+functions[compdef] )); then
+	compdef _{{.Func}}_complete {{.Name}}
+fi
+`)),
+	"fish": template.Must(template.New("fish").Parse(`
+function __{{.Func}}_complete
+	set -l prev (commandline -opc)
+	set -l cur (commandline -ct)
+	{{.Env}}jig __complete -- {{.Prefix}}$prev[2..-1] "$cur"
+end
+complete -c {{.Name}} -f -a '(__{{.Func}}_complete)'
+`)),
+}
+
+// script fills in the parts of a shell script that differ between commands
+type script struct {
+	// Name is the command being completed, or the function being defined
+	Name string
+	// Func is Name made safe to use in the names of helper functions
+	Func string
+	// Env sets JIGROOT for each call to jig, if it's to be set
+	Env string
+	// Prefix is passed to jig __complete before the words typed
+	Prefix string
+	// Files falls back to completing files
+	Files bool
+}
+
+// newScript returns the script parts for a command or function in a shell
+func newScript(shell, name, jigroot string) *script {
+	s := &script{Name: name}
+	s.Func = strings.Map(func(r rune) rune {
+		if r == '-' {
+			return '_'
+		}
+		return r
+	}, name)
+	if jigroot != "" {
+		if shell == "fish" {
+			s.Env = "env JIGROOT=" + fishQuote(jigroot) + " "
+		} else {
+			s.Env = "JIGROOT=" + shellQuote(jigroot) + " "
+		}
+	}
+	return s
+}
+
+// shellQuote quotes a string for bash and zsh
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// fishQuote quotes a string for fish
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// validShell exits if jig doesn't know a shell
+func validShell(shell string) {
+	if _, ok := completionScripts[shell]; !ok {
+		logrus.WithField("shell", shell).Fatalf("Unknown shell; use one of %s", strings.Join(shells, ", "))
+	}
+}
+
+// defaultShell guesses the shell in use, falling back to bash
+func defaultShell() string {
+	shell := filepath.Base(os.Getenv("SHELL"))
+	if _, ok := completionScripts[shell]; ok {
+		return shell
+	}
+	return "bash"
+}
+
+// repoArgs reports whether a command takes repositories as arguments, and
+// how many other arguments come before them, going by its usage line
+func repoArgs(cmd *cobra.Command) (int, bool) {
+	if cmd == lsCmd {
+		return 0, true
+	}
+	var before int
+	for _, field := range strings.Fields(cmd.Use)[1:] {
+		if strings.Contains(field, "repo") {
+			return before, true
+		}
+		if strings.HasPrefix(field, "<") {
+			before++
+		}
+	}
+	return 0, false
+}
+
+// flagValues are the values some flags can take
+var flagValues = map[string][]string{
+	"matcher": match.Matchers,
+	"shell":   shells,
+}
+
+// completions returns what could replace the last of args, the words
+// following jig on a command line
+func completions(args []string) []string {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	prev, word := args[:len(args)-1], args[len(args)-1]
+	cmd, rest, err := RootCmd.Find(prev)
+	if err != nil {
+		return nil
+	}
+	flags := pflag.NewFlagSet(cmd.Name(), pflag.ContinueOnError)
+	flags.AddFlagSet(cmd.Flags())
+	flags.AddFlagSet(cmd.PersistentFlags())
+	flags.AddFlagSet(cmd.InheritedFlags())
+	if len(rest) > 0 {
+		last := rest[len(rest)-1]
+		if strings.HasPrefix(last, "-") && !strings.Contains(last, "=") {
+			var f *pflag.Flag
+			if strings.HasPrefix(last, "--") {
+				f = flags.Lookup(last[2:])
+			} else if len(last) == 2 {
+				flags.VisitAll(func(flag *pflag.Flag) {
+					if flag.Shorthand == last[1:] {
+						f = flag
+					}
+				})
+			}
+			if f != nil && f.Value.Type() != "bool" {
+				return withPrefix(flagValues[f.Name], word)
+			}
+		}
+	}
+	var positional int
+	for _, arg := range rest {
+		if arg == "--" {
+			// Whatever comes after is someone else's
+			return nil
+		}
+		if !strings.HasPrefix(arg, "-") {
+			positional++
+		}
+	}
+	candidates := []string{}
+	if strings.HasPrefix(word, "-") {
+		flags.VisitAll(func(f *pflag.Flag) {
+			candidates = append(candidates, "--"+f.Name)
+			if f.Shorthand != "" {
+				candidates = append(candidates, "-"+f.Shorthand)
+			}
+		})
+		return withPrefix(candidates, word)
+	}
+	if positional == 0 {
+		for _, sub := range cmd.Commands() {
+			if !sub.Hidden && sub.Name() != "help" {
+				candidates = append(candidates, sub.Name())
+			}
+		}
+	}
+	if before, ok := repoArgs(cmd); ok && positional >= before {
+		candidates = append(candidates, repoNames(word, cmd == lsCmd)...)
+	}
+	return withPrefix(candidates, word)
+}
+
+// repoNames returns the names repositories can be selected by: their
+// aliases, and their paths and every trailing part of them. With subdirs, a
+// word naming a repository, a slash, and the start of a directory inside it
+// completes the directory.
+func repoNames(word string, subdirs bool) []string {
+	root, err := config.FindClosestJigRoot("")
+	if err != nil {
+		return nil
+	}
+	paths := map[string]bool{}
+	names := map[string][]string{}
+	if manifest, err := config.DefaultManifest(root); err == nil {
+		for _, r := range manifest.Repos {
+			if short, err := utils.RepoToPath(r.Repo); err == nil {
+				paths[short] = true
+				for _, alias := range r.Aliases {
+					names[alias] = append(names[alias], short)
+				}
+			}
+		}
+	}
+	if idx, err := config.DefaultIndex(root); err == nil {
+		for _, short := range idx.Paths() {
+			paths[short] = true
+		}
+	}
+	for short := range paths {
+		parts := strings.Split(short, "/")
+		for i := range parts {
+			name := strings.Join(parts[i:], "/")
+			names[name] = append(names[name], short)
+		}
+	}
+	result := []string{}
+	for name := range names {
+		if strings.HasPrefix(name, word) {
+			result = append(result, name)
+		}
+	}
+	if len(result) > 0 || !subdirs {
+		return result
+	}
+	for i := len(word) - 1; i > 0; i-- {
+		if word[i] != '/' {
+			continue
+		}
+		if repos := names[word[:i]]; len(repos) == 1 {
+			for _, dir := range fs.Subdirs(filepath.Join(root, repos[0]), subdirDepth) {
+				result = append(result, word[:i]+"/"+dir)
+			}
+			break
+		}
+	}
+	return result
+}
+
+// withPrefix returns the distinct candidates starting with prefix, in order
+func withPrefix(candidates []string, prefix string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) && !seen[c] {
+			seen[c] = true
+			result = append(result, c)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// completeCmd is what the completion scripts call
+var completeCmd = &cobra.Command{
+	Use:    "__complete -- [word...]",
+	Short:  "List what could complete a command line",
+	Hidden: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, c := range completions(args) {
+			fmt.Println(c)
+		}
+	},
+}
+
+// completionCmd represents the completion command
+var completionCmd = &cobra.Command{
+	Use:   "completion <bash|zsh|fish>",
+	Short: "Print a script that completes jig commands in your shell",
+	Long: `Print a script that completes jig commands, flags and repositories in bash,
+zsh or fish. Load it in your shell's startup file, for example:
+
+    eval "$(jig completion bash)"
+
+jig bootstrap includes it, along with completion for the cd function.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		shell := defaultShell()
+		if len(args) > 0 {
+			shell = args[0]
+		}
+		validShell(shell)
+		s := newScript(shell, "jig", "")
+		s.Files = true
+		if err := completionScripts[shell].Execute(os.Stdout, s); err != nil {
+			logrus.WithError(err).Fatal("Unable to write completion script")
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(completeCmd, completionCmd)
+}
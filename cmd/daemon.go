@@ -0,0 +1,99 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/daemon"
+	"github.com/iancmcc/jig/vcs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	daemonInterval time.Duration
+	daemonParallel int
+)
+
+// daemonCmd represents the daemon command
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Keep repositories fetched and their status fresh in the background",
+	Long: `Run until stopped, fetching every repository in the manifest now and then and
+recomputing its status whenever it changes. Queries for status, ls and root are
+answered as JSON over the Unix socket .jig/daemon.sock, and 'jig status' and
+'jig ls --all' use the daemon when it's running rather than running git
+themselves. Start it in the background with 'jig daemon &' and stop it with
+'jig daemon stop'.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		root, err := config.FindClosestJigRoot("")
+		if err != nil {
+			logrus.Fatal("No jig root found. Use 'jig init' to create one.")
+		}
+		applySettings(root)
+		// Our own git status shouldn't rewrite the index, which would look
+		// like another change
+		os.Setenv("GIT_OPTIONAL_LOCKS", "0")
+		server := daemon.NewServer(root, daemon.Options{
+			Interval: daemonInterval,
+			Parallel: daemonParallel,
+			Offline:  offline,
+		})
+		server.Seen = func(repo *config.Repo, stat *vcs.Status) {
+			saveStatus(root, map[string]string{stat.Repo: repo.Repo}, stat)
+		}
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			server.Stop()
+		}()
+		logrus.WithField("socket", daemon.SocketPath(root)).Info("Serving")
+		if err := server.Serve(); errors.Is(err, daemon.ErrRunning) {
+			logrus.Fatal("A daemon is already running for this jig root")
+		} else if err != nil {
+			logrus.WithError(err).Fatal("Unable to serve")
+		}
+	},
+}
+
+var daemonStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the daemon for this jig root",
+	Run: func(cmd *cobra.Command, args []string) {
+		root, err := config.FindClosestJigRoot("")
+		if err != nil {
+			logrus.Fatal("No jig root found. Use 'jig init' to create one.")
+		}
+		if _, err := daemon.Query(root, daemon.QueryStop); errors.Is(err, daemon.ErrNotRunning) {
+			logrus.Fatal("No daemon running for this jig root")
+		} else if err != nil {
+			logrus.WithError(err).Fatal("Unable to stop daemon")
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(daemonCmd)
+	daemonCmd.AddCommand(daemonStopCmd)
+	daemonCmd.Flags().DurationVar(&daemonInterval, "interval", 5*time.Minute, "Roughly how often to fetch each repository")
+	daemonCmd.Flags().IntVarP(&daemonParallel, "parallel", "j", 4, "Most git commands to run at once")
+}
@@ -0,0 +1,86 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/vcs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	depth         int
+	disableSparse bool
+)
+
+// deepenRepos fetches missing history for the selected repositories
+func deepenRepos(args []string, depth int) {
+	root, err := config.FindClosestJigRoot("")
+	if err != nil {
+		logrus.Fatal("No jig root found. Use 'jig init' to create one.")
+	}
+	manifest, err := config.DefaultManifest("")
+	if err != nil {
+		logrus.Fatal("No repo manifest to use. `jig restore` a manifest first.")
+	}
+	tasks := []vcs.Task{}
+	repos := selectRepos(manifest, args)
+	for _, repo := range repos {
+		log := logrus.WithField("repo", repo.Repo)
+		dir, err := repoDir(root, repo)
+		if err != nil {
+			log.WithError(err).Error("Unable to parse repo")
+			continue
+		}
+		deepenchan, err := vcs.Git.Deepen(repo, dir, depth, disableSparse)
+		if err != nil {
+			log.WithError(err).Error("Unable to deepen repo")
+			continue
+		}
+		tasks = append(tasks, vcs.NewTask(repo.Repo, deepenchan))
+	}
+	showProgress(tasks...)
+	indexRepos(root, repos...)
+}
+
+// unshallowCmd represents the unshallow command
+var unshallowCmd = &cobra.Command{
+	Use:   "unshallow [repo...]",
+	Short: "Convert shallow and partial clones into full clones",
+	Long: `Fetch the complete history of shallow clones and the objects omitted by
+partial clones, for all repositories in the manifest or just those named.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		deepenRepos(args, 0)
+	},
+}
+
+// deepenCmd represents the deepen command
+var deepenCmd = &cobra.Command{
+	Use:   "deepen [repo...]",
+	Short: "Fetch more history into shallow clones",
+	Run: func(cmd *cobra.Command, args []string) {
+		if depth < 1 {
+			logrus.Fatal("Depth must be at least 1. Use 'jig unshallow' to fetch the full history.")
+		}
+		deepenRepos(args, depth)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(unshallowCmd, deepenCmd)
+	unshallowCmd.Flags().BoolVar(&disableSparse, "no-sparse", false, "Also check out every file in sparse checkouts")
+	deepenCmd.Flags().IntVarP(&depth, "depth", "d", 100, "Number of commits of history to add")
+}
@@ -0,0 +1,266 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/fs"
+	"github.com/iancmcc/jig/utils"
+	"github.com/iancmcc/jig/vcs"
+	"github.com/spf13/cobra"
+)
+
+var fix bool
+
+// drift is a difference between the manifest and what's on disk
+type drift struct {
+	repo   *config.Repo
+	path   string
+	detail string
+}
+
+// diagnosis holds every kind of drift found in a Jig root
+type diagnosis struct {
+	// untracked repositories are on disk but not in the manifest
+	untracked []*drift
+	// misplaced repositories are on disk somewhere other than where their
+	// origin would be restored to
+	misplaced []*drift
+	// missing repositories are in the manifest but not on disk
+	missing []*drift
+	// notgit directories are where a manifest repository should be, but
+	// aren't git repositories
+	notgit []*drift
+	// moved repositories have an origin that differs from the manifest, once
+	// rewrite rules are applied
+	moved []*drift
+}
+
+func (d *diagnosis) problems() int {
+	return len(d.untracked) + len(d.misplaced) + len(d.missing) + len(d.notgit) + len(d.moved)
+}
+
+// fixable counts the problems that --fix knows how to fix
+func (d *diagnosis) fixable() int {
+	n := len(d.missing) + len(d.moved)
+	for _, u := range d.untracked {
+		if u.repo != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// normalizeURL strips the parts of a remote URL that don't change which
+// repository it names, so that an origin written with a trailing .git or
+// slash isn't reported as moved
+func normalizeURL(uri string) string {
+	uri = strings.TrimRight(uri, "/")
+	return strings.TrimRight(strings.TrimSuffix(uri, ".git"), "/")
+}
+
+// diagnose cross-checks the repositories under root with the manifest
+func diagnose(root string, manifest *config.Manifest) *diagnosis {
+	d := &diagnosis{}
+	known := map[string]*config.Repo{}
+	for _, r := range manifest.Repos {
+		short, err := utils.RepoToPath(r.Repo)
+		if err != nil {
+			logrus.WithField("repo", r.Repo).Error("Unable to parse repo")
+			continue
+		}
+		known[short] = r
+		dir := filepath.Join(root, short)
+		if _, err := os.Stat(dir); err != nil {
+			d.missing = append(d.missing, &drift{repo: r, path: short})
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+			d.notgit = append(d.notgit, &drift{repo: r, path: short})
+			continue
+		}
+		uri, _, err := vcs.RepoFromPath(dir)
+		if err != nil {
+			d.notgit = append(d.notgit, &drift{repo: r, path: short, detail: "no origin remote"})
+			continue
+		}
+		if normalizeURL(uri) != normalizeURL(vcs.Git.URL(r)) {
+			d.moved = append(d.moved, &drift{repo: r, path: short, detail: uri})
+		}
+	}
+
+	jigdir := config.JigRootDir(root) + string(filepath.Separator)
+	for dir := range fs.DefaultFinder().FindBelowWithChildrenNamed(root, ".git", 1) {
+		if strings.HasPrefix(dir, jigdir) {
+			continue
+		}
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			continue
+		}
+		if _, ok := known[filepath.ToSlash(rel)]; ok {
+			continue
+		}
+		uri, ref, err := vcs.RepoFromPath(dir)
+		if err != nil {
+			d.untracked = append(d.untracked, &drift{path: rel, detail: "no origin remote"})
+			continue
+		}
+		r := &config.Repo{Repo: uri, Ref: ref}
+		if short, err := utils.RepoToPath(uri); err == nil && short != filepath.ToSlash(rel) {
+			d.misplaced = append(d.misplaced, &drift{repo: r, path: rel, detail: short})
+			continue
+		}
+		d.untracked = append(d.untracked, &drift{repo: r, path: rel})
+	}
+	for _, drifts := range [][]*drift{d.untracked, d.misplaced} {
+		sort.Slice(drifts, func(i, j int) bool { return drifts[i].path < drifts[j].path })
+	}
+	return d
+}
+
+func printDrift(title string, drifts []*drift, describe func(*drift) string) {
+	if len(drifts) == 0 {
+		return
+	}
+	fmt.Printf("%s:\n", title)
+	for _, d := range drifts {
+		fmt.Printf("    %s\n", describe(d))
+	}
+	fmt.Println()
+}
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Find and fix differences between the manifest and the repositories on disk",
+	Long: `Cross-check the repositories under the Jig root with the manifest, and report
+repositories that aren't tracked, are missing, aren't git repositories, live
+somewhere other than their origin implies, or have a different origin than the
+manifest. With --fix, untracked repositories are added to the manifest, missing
+ones are cloned, and origins are updated to match the manifest.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		root, err := config.FindClosestJigRoot("")
+		if err != nil {
+			logrus.Fatal("No jig root found. Use 'jig init' to create one.")
+		}
+		manifest, err := config.DefaultManifest("")
+		if err != nil {
+			manifest = &config.Manifest{
+				Repos: []*config.Repo{},
+			}
+		}
+		settings := applySettings(root)
+		d := diagnose(root, manifest)
+
+		printDrift("Not in the manifest", d.untracked, func(d *drift) string {
+			if d.detail != "" {
+				return fmt.Sprintf("%s (%s)", d.path, d.detail)
+			}
+			return d.path
+		})
+		printDrift("Not where the origin belongs", d.misplaced, func(d *drift) string {
+			return fmt.Sprintf("%s (origin belongs at %s)", d.path, d.detail)
+		})
+		printDrift("Missing from disk", d.missing, func(d *drift) string {
+			return d.path
+		})
+		printDrift("Not a git repository", d.notgit, func(d *drift) string {
+			if d.detail != "" {
+				return fmt.Sprintf("%s (%s)", d.path, d.detail)
+			}
+			return d.path
+		})
+		printDrift("Origin differs from the manifest", d.moved, func(d *drift) string {
+			return fmt.Sprintf("%s: %s, manifest has %s", d.path, d.detail, vcs.Git.URL(d.repo))
+		})
+
+		if d.problems() == 0 {
+			fmt.Println("No problems found")
+			return
+		}
+		if !fix {
+			if d.fixable() > 0 {
+				fmt.Println("Run 'jig doctor --fix' to add untracked repositories, clone missing ones and update origins.")
+			}
+			os.Exit(1)
+		}
+
+		unfixed := len(d.misplaced) + len(d.notgit)
+		var added int
+		for _, u := range d.untracked {
+			if u.repo == nil {
+				unfixed++
+				continue
+			}
+			if err := manifest.Add(u.repo); err != nil {
+				logrus.WithError(err).WithField("repo", u.path).Error("Unable to add repository")
+				unfixed++
+				continue
+			}
+			added++
+		}
+		if added > 0 {
+			if err := manifest.Save(root); err != nil {
+				logrus.WithError(err).Fatal("Unable to save manifest")
+			}
+			fmt.Printf("Added %d repositories to the manifest\n", added)
+		}
+		for _, m := range d.moved {
+			if err := vcs.Git.SetOrigin(m.repo, filepath.Join(root, m.path)); err != nil {
+				logrus.WithError(err).WithField("repo", m.path).Error("Unable to update origin")
+				unfixed++
+				continue
+			}
+			fmt.Printf("Updated origin of %s\n", m.path)
+		}
+		if len(d.missing) > 0 {
+			opts := vcs.CloneOptions{
+				Filter: settings.Clone.Filter,
+				Sparse: settings.Clone.Sparse,
+			}
+			tasks := []vcs.Task{}
+			repos := []*config.Repo{}
+			for _, m := range d.missing {
+				clonechan, err := vcs.ApplyRepoConfig(root, vcs.Git, m.repo, opts)
+				if err != nil {
+					logrus.WithError(err).WithField("repo", m.path).Error("Unable to clone repository")
+					unfixed++
+					continue
+				}
+				tasks = append(tasks, vcs.NewTask(m.repo.Repo, clonechan))
+				repos = append(repos, m.repo)
+			}
+			unfixed += showProgress(tasks...)
+			indexRepos(root, repos...)
+		}
+		if unfixed > 0 {
+			fmt.Printf("%d problems need fixing by hand\n", unfixed)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().BoolVar(&fix, "fix", false, "Fix the problems that can be fixed automatically")
+}
@@ -0,0 +1,241 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/vcs"
+)
+
+// EventsVersion is the version of the event stream schema. It changes only
+// when fields are removed or change meaning; new fields and event types may
+// be added without a version change, so consumers should ignore what they
+// don't recognize.
+const EventsVersion = 1
+
+// Every event is a single JSON object on its own line, carrying the schema
+// version, its type and the time it was emitted:
+//
+//	{"version":1,"type":"start","time":"2016-05-01T12:00:00.000000001Z", ...}
+//
+// The types, and the fields they add, are:
+//
+//	start     repo, command            an operation on a repository began
+//	progress  repo, phase, message,    git reported progress; phase is one of
+//	          current, total, bytes,   counting, compressing, receiving,
+//	          begin, end               resolving, checkout or working
+//	error     repo, error              the operation on a repository failed
+//	finish    repo, ok, elapsed_ms     the operation on a repository ended
+//	summary   command, repos,          every operation has ended
+//	          succeeded, failed,
+//	          elapsed_ms
+//
+// Repositories are named by the path they are checked out to below the Jig
+// root. Numeric progress fields are omitted when git doesn't report them.
+type eventHeader struct {
+	Version int       `json:"version"`
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+}
+
+type startEvent struct {
+	eventHeader
+	Repo    string `json:"repo"`
+	Command string `json:"command"`
+}
+
+type progressEvent struct {
+	eventHeader
+	Repo    string `json:"repo"`
+	Phase   string `json:"phase"`
+	Message string `json:"message"`
+	Current int    `json:"current,omitempty"`
+	Total   int    `json:"total,omitempty"`
+	Bytes   int64  `json:"bytes,omitempty"`
+	Begin   bool   `json:"begin"`
+	End     bool   `json:"end"`
+}
+
+type errorEvent struct {
+	eventHeader
+	Repo  string `json:"repo"`
+	Error string `json:"error"`
+}
+
+type finishEvent struct {
+	eventHeader
+	Repo      string `json:"repo"`
+	OK        bool   `json:"ok"`
+	ElapsedMS int64  `json:"elapsed_ms"`
+}
+
+type summaryEvent struct {
+	eventHeader
+	Command   string `json:"command"`
+	Repos     int    `json:"repos"`
+	Succeeded int    `json:"succeeded"`
+	Failed    int    `json:"failed"`
+	ElapsedMS int64  `json:"elapsed_ms"`
+}
+
+var (
+	eventsDest string
+	// commandName is the jig subcommand being run, as reported in events
+	commandName string
+
+	eventsOnce sync.Once
+	events     *eventSink
+)
+
+// eventSink writes events to the destination given by --events
+type eventSink struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	stdout bool
+	broken bool
+}
+
+// openEventSink opens an event destination: "-" for stdout, "fd:N" for an
+// inherited file descriptor, "unix:PATH" for a listening Unix socket, or
+// anything else as a file to append to
+func openEventSink(dest string) (*eventSink, error) {
+	var w io.Writer
+	switch {
+	case dest == "-":
+		w = os.Stdout
+	case strings.HasPrefix(dest, "fd:"):
+		fd, err := strconv.Atoi(strings.TrimPrefix(dest, "fd:"))
+		if err != nil || fd < 0 {
+			return nil, fmt.Errorf("invalid file descriptor %q", dest)
+		}
+		w = os.NewFile(uintptr(fd), dest)
+	case strings.HasPrefix(dest, "unix:"):
+		conn, err := net.Dial("unix", strings.TrimPrefix(dest, "unix:"))
+		if err != nil {
+			return nil, err
+		}
+		w = conn
+	default:
+		f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		w = f
+	}
+	return &eventSink{enc: json.NewEncoder(w), stdout: dest == "-"}, nil
+}
+
+// eventStream returns the sink events should be written to, or nil if no
+// one asked for them
+func eventStream() *eventSink {
+	eventsOnce.Do(func() {
+		if eventsDest == "" {
+			return
+		}
+		sink, err := openEventSink(eventsDest)
+		if err != nil {
+			logrus.WithError(err).WithField("dest", eventsDest).Fatal("Unable to open event stream")
+		}
+		events = sink
+	})
+	return events
+}
+
+func header(typ string) eventHeader {
+	return eventHeader{Version: EventsVersion, Type: typ, Time: time.Now().UTC()}
+}
+
+func (s *eventSink) emit(event interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.broken {
+		return
+	}
+	if err := s.enc.Encode(event); err != nil {
+		// Whoever was listening has gone away; carry on without them
+		logrus.WithError(err).Warn("Unable to write to event stream")
+		s.broken = true
+	}
+}
+
+// tee emits events for tasks as they are consumed. The returned function
+// waits for every task to finish and emits the summary.
+func (s *eventSink) tee(tasks []vcs.Task) ([]vcs.Task, func()) {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed int
+		start  = time.Now()
+	)
+	teed := make([]vcs.Task, 0, len(tasks))
+	for _, t := range tasks {
+		out := make(chan vcs.Progress)
+		teed = append(teed, vcs.Task{Repo: t.Repo, Progress: out})
+		s.emit(startEvent{header("start"), t.Repo, commandName})
+		wg.Add(1)
+		go func(t vcs.Task) {
+			defer wg.Done()
+			defer close(out)
+			began := time.Now()
+			ok := true
+			for p := range t.Progress {
+				if p.Err != nil {
+					ok = false
+					s.emit(errorEvent{header("error"), t.Repo, p.Err.Error()})
+				} else {
+					s.emit(progressEvent{
+						eventHeader: header("progress"),
+						Repo:        t.Repo,
+						Phase:       p.Phase.String(),
+						Message:     p.Message,
+						Current:     p.Current,
+						Total:       p.Total,
+						Bytes:       p.Bytes,
+						Begin:       p.IsBegin,
+						End:         p.IsEnd,
+					})
+				}
+				out <- p
+			}
+			if !ok {
+				mu.Lock()
+				failed++
+				mu.Unlock()
+			}
+			s.emit(finishEvent{header("finish"), t.Repo, ok, time.Since(began).Milliseconds()})
+		}(t)
+	}
+	return teed, func() {
+		wg.Wait()
+		s.emit(summaryEvent{
+			eventHeader: header("summary"),
+			Command:     commandName,
+			Repos:       len(tasks),
+			Succeeded:   len(tasks) - failed,
+			Failed:      failed,
+			ElapsedMS:   time.Since(start).Milliseconds(),
+		})
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	topo     bool
+	parallel int
+)
+
+// execResult is the outcome of running a command in a repository
+type execResult struct {
+	repo    string
+	output  []byte
+	err     error
+	skipped bool
+}
+
+// execRunner runs a command in repositories, at most parallel at a time,
+// printing the output of each as it finishes
+type execRunner struct {
+	root    string
+	command []string
+	sem     chan struct{}
+	mu      sync.Mutex
+	failed  int
+}
+
+func newExecRunner(root string, command []string, parallel int) *execRunner {
+	r := &execRunner{root: root, command: command}
+	if parallel > 0 {
+		r.sem = make(chan struct{}, parallel)
+	}
+	return r
+}
+
+func (x *execRunner) run(name string) execResult {
+	if x.sem != nil {
+		x.sem <- struct{}{}
+		defer func() { <-x.sem }()
+	}
+	var c *exec.Cmd
+	if len(x.command) == 1 {
+		c = exec.Command("sh", "-c", x.command[0])
+	} else {
+		c = exec.Command(x.command[0], x.command[1:]...)
+	}
+	c.Dir = filepath.Join(x.root, name)
+	c.Env = append(os.Environ(), "JIG_ROOT="+x.root, "JIG_REPO="+name)
+	output, err := c.CombinedOutput()
+	return execResult{repo: name, output: output, err: err}
+}
+
+func (x *execRunner) report(res execResult) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	switch {
+	case res.skipped:
+		x.failed++
+		fmt.Printf("==> %s (skipped: a dependency failed)\n", res.repo)
+		return
+	case res.err != nil:
+		x.failed++
+		fmt.Printf("==> %s (failed: %s)\n", res.repo, res.err)
+	default:
+		fmt.Printf("==> %s\n", res.repo)
+	}
+	os.Stdout.Write(res.output)
+}
+
+// all runs the command in every repository at once
+func (x *execRunner) all(names []string) {
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			x.report(x.run(name))
+		}(name)
+	}
+	wg.Wait()
+}
+
+// topo runs the command a level of the dependency graph at a time, skipping
+// repositories whose dependencies failed or were skipped. Dependencies that
+// weren't selected are assumed to be fine.
+func (x *execRunner) topo(graph *config.Graph, names []string) {
+	selected := map[string]struct{}{}
+	for _, name := range names {
+		selected[name] = struct{}{}
+	}
+	var (
+		mu  sync.Mutex
+		bad = map[string]struct{}{}
+	)
+	for _, level := range graph.Levels() {
+		var wg sync.WaitGroup
+		for _, name := range level {
+			if _, ok := selected[name]; !ok {
+				continue
+			}
+			var blocked bool
+			mu.Lock()
+			for _, dep := range graph.DependsOn(name) {
+				if _, ok := bad[dep]; ok {
+					blocked = true
+				}
+			}
+			if blocked {
+				bad[name] = struct{}{}
+			}
+			mu.Unlock()
+			if blocked {
+				x.report(execResult{repo: name, skipped: true})
+				continue
+			}
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+				res := x.run(name)
+				if res.err != nil {
+					mu.Lock()
+					bad[name] = struct{}{}
+					mu.Unlock()
+				}
+				x.report(res)
+			}(name)
+		}
+		wg.Wait()
+	}
+}
+
+// execCmd represents the exec command
+var execCmd = &cobra.Command{
+	Use:   "exec [repo...] -- <command> [args...]",
+	Short: "Run a command in every repository",
+	Long: `Run a command in all repositories in the manifest, or just those named, in
+parallel. A single argument is run by the shell. The output of each repository
+is printed when it finishes, and JIG_ROOT and JIG_REPO are set in the
+environment of the command.
+
+With --topo, repositories run after the ones they depend on, a level of the
+dependency graph at a time, and are skipped if a dependency failed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var selectors, command []string
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			selectors, command = args[:dash], args[dash:]
+		} else {
+			command = args
+		}
+		if len(command) == 0 {
+			logrus.Fatal("Must pass a command to run")
+		}
+		root, err := config.FindClosestJigRoot("")
+		if err != nil {
+			logrus.Fatal("No jig root found. Use 'jig init' to create one.")
+		}
+		manifest, err := config.DefaultManifest("")
+		if err != nil {
+			logrus.Fatal("No repo manifest to use. `jig restore` a manifest first.")
+		}
+		names := []string{}
+		repos := []*config.Repo{}
+		for _, repo := range selectRepos(manifest, selectors) {
+			name, err := utils.RepoToPath(repo.Repo)
+			if err != nil {
+				logrus.WithField("repo", repo.Repo).Error("Unable to parse repo")
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(root, name)); err != nil {
+				logrus.WithField("repo", name).Warn("Skipping repo that hasn't been cloned")
+				continue
+			}
+			names = append(names, name)
+			repos = append(repos, repo)
+		}
+		runner := newExecRunner(root, command, parallel)
+		if topo {
+			graph, err := manifest.Graph()
+			if err != nil {
+				logrus.WithError(err).Fatal("Unable to order repositories by dependency")
+			}
+			runner.topo(graph, names)
+		} else {
+			runner.all(names)
+		}
+		// The command may well have changed them
+		indexRepos(root, repos...)
+		if runner.failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(execCmd)
+	execCmd.Flags().BoolVarP(&topo, "topo", "t", false, "Run repositories after the ones they depend on")
+	execCmd.Flags().IntVarP(&parallel, "parallel", "j", 0, "Run in at most this many repositories at once (default is no limit)")
+}
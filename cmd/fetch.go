@@ -0,0 +1,88 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/utils"
+	"github.com/iancmcc/jig/vcs"
+	"github.com/spf13/cobra"
+)
+
+// fetchCmd represents the fetch command
+var fetchCmd = &cobra.Command{
+	Use:   "fetch [repo...]",
+	Short: "Update remote-tracking branches without touching working trees",
+	Long: `Fetch from the remotes of all repositories in the manifest, or just those
+named, then list the branches that have commits waiting to be pulled. Working
+trees are left alone, so 'jig pull --offline' can apply the changes later.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if offline {
+			logrus.Fatal("Can't fetch while working offline")
+		}
+		root, err := config.FindClosestJigRoot("")
+		if err != nil {
+			logrus.Fatal("No jig root found. Use 'jig init' to create one.")
+		}
+		manifest, err := config.DefaultManifest("")
+		if err != nil {
+			logrus.Fatal("No repo manifest to use to fetch. `jig restore` a manifest first.")
+		}
+		applySettings(root)
+		repos := []*config.Repo{}
+		tasks := []vcs.Task{}
+		for _, repo := range selectRepos(manifest, args) {
+			log := logrus.WithField("repo", repo.Repo)
+			dir, err := repoDir(root, repo)
+			if err != nil {
+				log.WithError(err).Error("Unable to parse repo")
+				continue
+			}
+			if _, err := os.Stat(dir); err != nil {
+				log.Warn("Skipping repo that hasn't been cloned")
+				continue
+			}
+			fetchchan, err := vcs.Git.Fetch(repo, dir)
+			if err != nil {
+				log.WithError(err).Error("Unable to fetch repo")
+				continue
+			}
+			repos = append(repos, repo)
+			tasks = append(tasks, vcs.NewTask(repo.Repo, fetchchan))
+		}
+		showProgress(tasks...)
+		if sink := eventStream(); sink != nil && sink.stdout {
+			return
+		}
+		for _, repo := range repos {
+			dir, _ := repoDir(root, repo)
+			incoming, err := vcs.Git.Incoming(repo, dir)
+			if err != nil || incoming == 0 {
+				continue
+			}
+			branch, _, _ := vcs.Git.Branch(repo, dir)
+			short, _ := utils.RepoToPath(repo.Repo)
+			fmt.Printf("%s: %d incoming on %s\n", short, incoming, branch)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(fetchCmd)
+}
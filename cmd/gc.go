@@ -0,0 +1,117 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/utils"
+	"github.com/iancmcc/jig/vcs"
+	"github.com/spf13/cobra"
+)
+
+var gcOpts vcs.GCOptions
+
+// gcCmd represents the gc command
+var gcCmd = &cobra.Command{
+	Use:   "gc [repo...]",
+	Short: "Prune merged branches and stale refs, and compact repositories",
+	Long: `Prune remote-tracking refs for branches deleted upstream, delete local
+branches fully merged into their upstream or the manifest ref, and run
+'git gc --auto' in every repository in the manifest, or just those named. The
+checked out branch is never deleted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		root, err := config.FindClosestJigRoot("")
+		if err != nil {
+			logrus.Fatal("No jig root found. Use 'jig init' to create one.")
+		}
+		manifest, err := config.DefaultManifest("")
+		if err != nil {
+			logrus.Fatal("No repo manifest to use. `jig restore` a manifest first.")
+		}
+		repos := selectRepos(manifest, args)
+		results := make([]*vcs.GCResult, len(repos))
+		var wg sync.WaitGroup
+		for i, r := range repos {
+			dir, err := repoDir(root, r)
+			if err != nil {
+				logrus.WithField("repo", r.Repo).Error("Unable to parse repo")
+				continue
+			}
+			wg.Add(1)
+			go func(i int, r *config.Repo, dir string) {
+				defer wg.Done()
+				results[i] = vcs.Git.GC(r, dir, gcOpts)
+			}(i, r, dir)
+		}
+		wg.Wait()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 5, 4, ' ', 0)
+		if gcOpts.DryRun {
+			fmt.Fprintf(w, "Repo\tWould prune\tWould delete\n")
+		} else {
+			fmt.Fprintf(w, "Repo\tPruned\tDeleted\tReclaimed\n")
+		}
+		var reclaimed int64
+		failed := []*vcs.GCResult{}
+		for _, result := range results {
+			if result == nil {
+				continue
+			}
+			if result.Err != nil || result.FsckErr != nil {
+				failed = append(failed, result)
+			}
+			if result.Err != nil {
+				continue
+			}
+			pruned := strings.Join(result.PrunedRefs, ", ")
+			deleted := strings.Join(result.DeletedBranches, ", ")
+			if gcOpts.DryRun {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", result.Repo, pruned, deleted)
+				continue
+			}
+			reclaimed += result.Reclaimed
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Repo, pruned, deleted, utils.HumanBytes(result.Reclaimed))
+		}
+		w.Flush()
+		if !gcOpts.DryRun {
+			fmt.Printf("\nReclaimed %s\n", utils.HumanBytes(reclaimed))
+		}
+		for _, result := range failed {
+			log := logrus.WithField("repo", result.Repo)
+			if result.Err != nil {
+				log.WithError(result.Err).Error("Unable to clean up repo")
+			}
+			if result.FsckErr != nil {
+				log.WithError(result.FsckErr).Error("Repository failed integrity check")
+			}
+		}
+		if len(failed) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(gcCmd)
+	gcCmd.Flags().BoolVarP(&gcOpts.DryRun, "dry-run", "n", false, "Print what would be deleted without deleting anything")
+	gcCmd.Flags().BoolVar(&gcOpts.Fsck, "fsck", false, "Also verify the integrity of each repository")
+}
@@ -0,0 +1,77 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dot bool
+)
+
+// graphCmd represents the graph command
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Print the dependency graph of the repositories in your manifest",
+	Long: `Print the repositories in the manifest a level at a time, each after the
+repositories it depends on. With --dot, print the graph in Graphviz DOT format
+instead, with edges pointing from each repository to the ones that build on
+it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest, err := config.DefaultManifest("")
+		if err != nil {
+			logrus.Fatal("No repo manifest to use. `jig restore` a manifest first.")
+		}
+		graph, err := manifest.Graph()
+		if err != nil {
+			logrus.WithError(err).Fatal("Unable to build dependency graph")
+		}
+		if dot {
+			fmt.Println("digraph jig {")
+			fmt.Println("    rankdir=LR;")
+			for _, name := range graph.Nodes {
+				fmt.Printf("    %q;\n", name)
+			}
+			for _, name := range graph.Nodes {
+				for _, dep := range graph.DependsOn(name) {
+					fmt.Printf("    %q -> %q;\n", dep, name)
+				}
+			}
+			fmt.Println("}")
+			return
+		}
+		for i, level := range graph.Levels() {
+			fmt.Printf("Level %d:\n", i)
+			for _, name := range level {
+				if deps := graph.DependsOn(name); len(deps) > 0 {
+					fmt.Printf("    %s (depends on %s)\n", name, strings.Join(deps, ", "))
+				} else {
+					fmt.Printf("    %s\n", name)
+				}
+			}
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(graphCmd)
+	graphCmd.Flags().BoolVar(&dot, "dot", false, "Print the graph in Graphviz DOT format")
+}
@@ -0,0 +1,180 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/hosting"
+	"github.com/iancmcc/jig/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importOrg      string
+	importGroup    string
+	importTopics   []string
+	importArchived bool
+	importMatch    string
+	importAPI      string
+	importToken    string
+	importDryRun   bool
+)
+
+// importRepos lists the repositories of an organization or group and adds
+// those passing the filters to the manifest
+func importRepos(lister hosting.Lister, org string) {
+	root, err := config.FindClosestJigRoot("")
+	if err != nil {
+		logrus.Fatal("No jig root found. Use 'jig init' to create one.")
+	}
+	filter := hosting.Filter{
+		Topics:   importTopics,
+		Archived: importArchived,
+	}
+	if importMatch != "" {
+		if filter.Name, err = regexp.Compile(importMatch); err != nil {
+			logrus.WithError(err).Fatal("Invalid --match expression")
+		}
+	}
+	listed, err := lister.List(org)
+	if err != nil {
+		logrus.WithError(err).Fatal("Unable to list repositories")
+	}
+	manifest, err := config.DefaultManifest("")
+	if err != nil {
+		manifest = &config.Manifest{
+			Repos: []*config.Repo{},
+		}
+	}
+	existing := map[string]struct{}{}
+	for _, r := range manifest.Repos {
+		if short, err := utils.RepoToPath(r.Repo); err == nil {
+			existing[short] = struct{}{}
+		}
+	}
+	var added int
+	verb := "Added"
+	if importDryRun {
+		verb = "Would add"
+	}
+	for _, r := range filter.Apply(listed) {
+		if r.DefaultBranch == "" {
+			logrus.WithField("repo", r.Path).Debug("Skipping empty repository")
+			continue
+		}
+		repo := &config.Repo{
+			Repo: r.CloneURL,
+			Ref:  r.DefaultBranch,
+		}
+		short, err := utils.RepoToPath(repo.Repo)
+		if err != nil {
+			logrus.WithField("repo", r.CloneURL).Warn("Unable to parse repository URL")
+			continue
+		}
+		// Keep whatever the manifest already says about a repository
+		if _, ok := existing[short]; ok {
+			continue
+		}
+		if err := manifest.Add(repo); err != nil {
+			logrus.WithField("repo", r.CloneURL).Warn("Unable to add repository")
+			continue
+		}
+		existing[short] = struct{}{}
+		fmt.Printf("%s %s (%s)\n", verb, short, repo.Ref)
+		added++
+	}
+	if added == 0 {
+		fmt.Printf("No new repositories to add from %s (%d listed)\n", org, len(listed))
+		return
+	}
+	if importDryRun {
+		return
+	}
+	if err := manifest.Save(root); err != nil {
+		logrus.WithError(err).Fatal("Unable to save manifest")
+	}
+}
+
+// tokenFrom returns the token passed, or the one in an environment
+// variable
+func tokenFrom(env string) string {
+	if importToken != "" {
+		return importToken
+	}
+	return os.Getenv(env)
+}
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Add the repositories of a hosting organization to the manifest",
+	Long: `List the repositories of a GitHub organization or GitLab group through the
+host's API and add them to the manifest, tracking their default branches.
+Archived repositories are left out unless --archived is passed, and
+repositories already in the manifest are left as they are. Run 'jig restore'
+afterwards to clone them.`,
+}
+
+var importGitHubCmd = &cobra.Command{
+	Use:   "github --org <org>",
+	Short: "Add the repositories of a GitHub organization",
+	Long: `Add the repositories of a GitHub organization to the manifest. For GitHub
+Enterprise, pass --api-url https://HOST/api/v3. A token in GITHUB_TOKEN or
+--token lists private repositories too.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if importOrg == "" {
+			logrus.Fatal("Must pass the organization to import with --org")
+		}
+		importRepos(&hosting.GitHub{
+			BaseURL: importAPI,
+			Token:   tokenFrom("GITHUB_TOKEN"),
+		}, importOrg)
+	},
+}
+
+var importGitLabCmd = &cobra.Command{
+	Use:   "gitlab --group <group>",
+	Short: "Add the projects of a GitLab group and its subgroups",
+	Long: `Add the projects of a GitLab group and its subgroups to the manifest. For
+self-managed GitLab, pass --api-url https://HOST/api/v4. A token in
+GITLAB_TOKEN or --token lists private projects too.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if importGroup == "" {
+			logrus.Fatal("Must pass the group to import with --group")
+		}
+		importRepos(&hosting.GitLab{
+			BaseURL: importAPI,
+			Token:   tokenFrom("GITLAB_TOKEN"),
+		}, importGroup)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(importCmd)
+	importCmd.AddCommand(importGitHubCmd, importGitLabCmd)
+	importGitHubCmd.Flags().StringVar(&importOrg, "org", "", "The organization whose repositories to add")
+	importGitLabCmd.Flags().StringVar(&importGroup, "group", "", "The group whose projects to add, e.g. acme/platform")
+	importCmd.PersistentFlags().StringSliceVar(&importTopics, "topic", nil, "Only add repositories with this topic; repeat to require several")
+	importCmd.PersistentFlags().BoolVar(&importArchived, "archived", false, "Add archived repositories too")
+	importCmd.PersistentFlags().StringVar(&importMatch, "match", "", "Only add repositories whose names match this regular expression")
+	importCmd.PersistentFlags().StringVar(&importAPI, "api-url", "", "Base URL of the host's REST API, for GitHub Enterprise or self-managed GitLab")
+	importCmd.PersistentFlags().StringVar(&importToken, "token", "", "API token (default $GITHUB_TOKEN or $GITLAB_TOKEN)")
+	importCmd.PersistentFlags().BoolVarP(&importDryRun, "dry-run", "n", false, "Show what would be added without changing the manifest")
+}
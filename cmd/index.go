@@ -0,0 +1,147 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/utils"
+	"github.com/iancmcc/jig/vcs"
+)
+
+// loadIndex loads the index of the Jig root and brings it up to date with the
+// repositories checked out, saving it if anything changed. With force, the
+// whole tree is read again.
+func loadIndex(root string, force bool) *config.Index {
+	idx, err := config.DefaultIndex(root)
+	if err != nil {
+		logrus.WithError(err).Warn("Unable to load index")
+		idx = config.NewIndex()
+	}
+	changed := idx.Refresh(root, force)
+	if manifest, err := config.DefaultManifest(root); err == nil {
+		for _, r := range manifest.Repos {
+			short, err := utils.RepoToPath(r.Repo)
+			if err != nil {
+				continue
+			}
+			if entry, ok := idx.Repos[short]; ok && entry.Repo != r.Repo {
+				entry.Repo = r.Repo
+				changed = true
+			}
+		}
+	}
+	if changed {
+		if err := idx.Save(root); err != nil {
+			logrus.WithError(err).Warn("Unable to save index")
+		}
+	}
+	return idx
+}
+
+// indexedStatus returns the status last seen for a repository, or nil if
+// there isn't one or its branch may have changed since. Whether the
+// repository is dirty is as it was, and needs checking again.
+func indexedStatus(root string, idx *config.Index, repo *config.Repo) *vcs.Status {
+	short, err := utils.RepoToPath(repo.Repo)
+	if err != nil {
+		return nil
+	}
+	entry, ok := idx.Repos[short]
+	if !ok || !entry.Unchanged(filepath.Join(root, short)) {
+		return nil
+	}
+	return &vcs.Status{
+		Repo:      short,
+		OrigRef:   repo.Ref,
+		Branch:    entry.Branch,
+		URL:       vcs.Git.URL(repo),
+		Staged:    entry.Status.Staged,
+		Unstaged:  entry.Status.Unstaged,
+		Untracked: entry.Status.Untracked,
+		Ahead:     entry.Status.Ahead,
+		Behind:    entry.Status.Behind,
+		Shape: vcs.Shape{
+			Shallow: entry.Status.Shallow,
+			Partial: entry.Status.Partial,
+			Sparse:  entry.Status.Sparse,
+		},
+	}
+}
+
+// saveStatus records statuses just seen in the index
+func saveStatus(root string, uris map[string]string, stats ...*vcs.Status) {
+	if len(stats) == 0 {
+		return
+	}
+	idx, err := config.DefaultIndex(root)
+	if err != nil {
+		logrus.WithError(err).Warn("Unable to load index")
+		return
+	}
+	now := time.Now().UTC()
+	for _, stat := range stats {
+		entry := idx.Set(stat.Repo)
+		if uri, ok := uris[stat.Repo]; ok {
+			entry.Repo = uri
+		}
+		entry.Branch = stat.Branch
+		entry.Status = &config.IndexedStatus{
+			Staged:    stat.Staged,
+			Unstaged:  stat.Unstaged,
+			Untracked: stat.Untracked,
+			Shallow:   stat.Shallow,
+			Partial:   stat.Partial,
+			Sparse:    stat.Sparse,
+			Ahead:     stat.Ahead,
+			Behind:    stat.Behind,
+			Seen:      now,
+		}
+	}
+	if err := idx.Save(root); err != nil {
+		logrus.WithError(err).Warn("Unable to save index")
+	}
+}
+
+// indexRepos records the branch and status of repositories in the index,
+// after jig has changed them
+func indexRepos(root string, repos ...*config.Repo) {
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		stats = []*vcs.Status{}
+		uris  = map[string]string{}
+	)
+	for _, repo := range repos {
+		wg.Add(1)
+		go func(repo *config.Repo) {
+			defer wg.Done()
+			stat, err := repoStatus(root, repo)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			stats = append(stats, stat)
+			uris[stat.Repo] = repo.Repo
+		}(repo)
+	}
+	wg.Wait()
+	saveStatus(root, uris, stats...)
+}
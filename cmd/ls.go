@@ -15,37 +15,133 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/daemon"
 	"github.com/iancmcc/jig/fs"
 	"github.com/iancmcc/jig/match"
+	"github.com/iancmcc/jig/picker"
 	"github.com/iancmcc/jig/utils"
 	"github.com/spf13/cobra"
 )
 
+// subdirDepth is how deep into a repository ls looks for a directory to
+// match
+const subdirDepth = 6
+
 var (
-	limit int
-	all   bool
+	limit       int
+	all         bool
+	worktree    string
+	refresh     bool
+	matcher     string
+	stats       bool
+	forget      bool
+	interactive bool
 )
 
 // lsCmd represents the ls command
 var lsCmd = &cobra.Command{
 	Use:   "ls",
 	Short: "List repositories",
-	Long:  `List repositories below the current directory, optionally sorted by similarity to a search string`,
+	Long: `List repositories below the current directory, optionally sorted by similarity to a search string.
+
+Every word of the search has to match. A search like jig/cmd matches the
+repository jig, then the directory cmd inside it. A search that's exactly an
+alias (see jig alias) matches its repository first.
+
+With --all, repositories are listed from the index of the Jig root, which only
+reads directories that have changed since it was last updated; --refresh
+reads them all again.
+
+Each search with --limit 1, as the cdj shell function makes, is remembered in
+the history of the Jig root, and repositories visited often and lately rank
+ahead of others that match about as well. --stats shows the history, and
+--forget drops the repositories named from it.
+
+With --interactive, matches are shown as you type, along with the branch each
+repository has checked out and whether it's dirty, and the one chosen is
+printed.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		here, _ := filepath.Abs("")
 		root, err := config.FindClosestJigRoot("")
 		if err != nil {
 			logrus.Fatal("No jig root found. Use 'jig init' to create one.")
 		}
+		base := root
+		if worktree != "" {
+			base = config.WorktreeRoot(root, worktree)
+			if _, err := os.Stat(base); err != nil {
+				logrus.WithField("set", worktree).Fatal("No such worktree set")
+			}
+		}
+		history, err := config.DefaultHistory(root)
+		if err != nil {
+			logrus.WithError(err).Fatal("Unable to read history")
+		}
+		if stats {
+			historyStats(history)
+			return
+		}
+		if forget {
+			forgetHistory(root, history, args)
+			return
+		}
+		// Stops the goroutines finding repositories, should the rest not be
+		// wanted
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
 		var repos <-chan string
-		if all {
-			repos = fs.DefaultFinder().FindBelowWithChildrenNamed(root, ".git", 1)
+		if all && worktree == "" {
+			ch := make(chan string)
+			repos = ch
+			var paths []string
+			if !refresh {
+				if resp, err := daemon.Query(root, daemon.QueryLs); err == nil {
+					paths = resp.Repos
+				}
+			}
+			if paths == nil {
+				paths = loadIndex(root, refresh).Paths()
+			}
+			go func() {
+				defer close(ch)
+				for _, path := range paths {
+					select {
+					case ch <- filepath.Join(root, path):
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		} else if all {
+			found := fs.DefaultFinder().FindBelowWithChildrenNamedContext(ctx, base, ".git", 1)
+			ch := make(chan string)
+			repos = ch
+			go func() {
+				defer close(ch)
+				// Skip worktree sets and anything else jig keeps for itself
+				jigdir := config.JigRootDir(base) + string(filepath.Separator)
+				for repo := range found {
+					if strings.HasPrefix(repo, jigdir) {
+						continue
+					}
+					select {
+					case ch <- repo:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
 		} else {
 			ch := make(chan string)
 			repos = ch
@@ -60,10 +156,52 @@ var lsCmd = &cobra.Command{
 					if err != nil {
 						continue
 					}
-					ch <- filepath.Join(root, path)
+					path = filepath.Join(base, path)
+					if worktree != "" {
+						// Worktree sets only hold some of the manifest
+						if _, err := os.Stat(path); err != nil {
+							continue
+						}
+					}
+					select {
+					case ch <- path:
+					case <-ctx.Done():
+						return
+					}
 				}
 			}()
 		}
+		now := time.Now()
+		frecency := history.Frecency(now)
+		rank := func(m match.Matcher) []string {
+			return match.Rank(m, func(repo string) float64 {
+				return frecency[strings.Trim(repo, "/")]
+			})
+		}
+		if interactive {
+			if _, err := match.NewMatcher(matcher, ""); err != nil {
+				logrus.WithError(err).Fatal("Unknown matcher")
+			}
+			paths := []string{}
+			for repo := range repos {
+				paths = append(paths, strings.Trim(strings.TrimPrefix(repo, base), "/"))
+			}
+			// Before anything's typed, the places visited most come first
+			sort.SliceStable(paths, func(i, j int) bool { return frecency[paths[i]] > frecency[paths[j]] })
+			picked, err := pickRepo(root, base, paths, strings.Join(args, " "), rank)
+			if err == picker.ErrCancelled {
+				os.Exit(1)
+			} else if err != nil {
+				logrus.WithError(err).Fatal("Unable to pick interactively")
+			}
+			rel, _ := filepath.Rel(here, filepath.Join(base, picked))
+			fmt.Println(rel)
+			history.Record(picked, now)
+			if err := history.Save(root); err != nil {
+				logrus.WithError(err).Debug("Unable to save history")
+			}
+			return
+		}
 		if len(args) == 0 {
 			var i int
 			for repo := range repos {
@@ -76,22 +214,158 @@ var lsCmd = &cobra.Command{
 			}
 			return
 		}
-		matcher := match.DefaultMatcher(args[0])
+		candidates := []string{}
 		for repo := range repos {
-			matcher.Add(strings.TrimPrefix(repo, root))
+			candidates = append(candidates, strings.TrimPrefix(repo, base))
+		}
+		aliases := map[string]string{}
+		if manifest, err := config.DefaultManifest(root); err == nil {
+			for _, r := range manifest.Repos {
+				if short, err := utils.RepoToPath(r.Repo); err == nil {
+					for _, alias := range r.Aliases {
+						aliases[alias] = short
+					}
+				}
+			}
 		}
-		for i, repo := range matcher.Match() {
+		ranked, subdirs := resolve(base, candidates, args, aliases, rank)
+		results := ranked
+		if subdirs != nil {
+			results = make([]string, len(subdirs))
+			for i, dir := range subdirs {
+				results[i] = filepath.Join(ranked[0], dir)
+			}
+		}
+		for i, result := range results {
 			if limit > 0 && i >= limit {
 				break
 			}
-			rel, _ := filepath.Rel(here, filepath.Join(root, repo))
+			rel, _ := filepath.Rel(here, filepath.Join(base, result))
 			fmt.Println(rel)
 		}
+		// A single result is somewhere to go, so remember going there
+		if limit == 1 && len(ranked) > 0 {
+			history.Record(strings.Trim(ranked[0], "/"), now)
+			if err := history.Save(root); err != nil {
+				logrus.WithError(err).Debug("Unable to save history")
+			}
+		}
 	},
 }
 
+// resolve matches a query against candidate repositories. Every token has to
+// match. The last token may also name a directory inside the repository, as
+// in jig/cmd: the longest part of it before a / that matches a repository is
+// taken to name it, and the rest is matched against the directories in the
+// best match. A query that's exactly an alias, keyed to the path of its
+// repository in aliases, matches that repository first. The repositories
+// matched are returned best first, along with the directories matched in the
+// first, if there were any.
+func resolve(base string, candidates, tokens []string, aliases map[string]string, rank func(m match.Matcher) []string) ([]string, []string) {
+	last := tokens[len(tokens)-1]
+	splits := []int{len(last)}
+	for i := len(last) - 1; i > 0; i-- {
+		if last[i] == '/' {
+			splits = append(splits, i)
+		}
+	}
+	for _, at := range splits {
+		query := append(append([]string{}, tokens[:len(tokens)-1]...), last[:at])
+		m, err := match.NewTokensMatcher(matcher, query)
+		if err != nil {
+			logrus.WithError(err).Fatal("Unknown matcher")
+		}
+		for _, c := range candidates {
+			m.Add(c)
+		}
+		ranked := rank(m)
+		if short, ok := aliases[query[0]]; ok && len(query) == 1 {
+			for _, c := range candidates {
+				if strings.Trim(c, "/") != short {
+					continue
+				}
+				aliased := []string{c}
+				for _, r := range ranked {
+					if r != c {
+						aliased = append(aliased, r)
+					}
+				}
+				ranked = aliased
+			}
+		}
+		if len(ranked) == 0 {
+			continue
+		}
+		subpath := strings.Trim(last[at:], "/")
+		if subpath == "" {
+			return ranked, nil
+		}
+		sm, _ := match.NewMatcher(matcher, subpath)
+		for _, dir := range fs.Subdirs(filepath.Join(base, ranked[0]), subdirDepth) {
+			sm.Add(dir)
+		}
+		if subdirs := sm.Match(); len(subdirs) > 0 {
+			return ranked[:1], subdirs
+		}
+	}
+	return []string{}, nil
+}
+
+// historyStats prints the repositories in the history, most frecent first
+func historyStats(history *config.History) {
+	now := time.Now()
+	frecency := history.Frecency(now)
+	paths := make([]string, 0, len(frecency))
+	for path := range frecency {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if frecency[paths[i]] != frecency[paths[j]] {
+			return frecency[paths[i]] > frecency[paths[j]]
+		}
+		return paths[i] < paths[j]
+	})
+	w := tabwriter.NewWriter(os.Stdout, 0, 5, 4, ' ', 0)
+	fmt.Fprintln(w, "Repo\tVisits\tLast visited\tFrecency")
+	for _, path := range paths {
+		v := history.Visits[path]
+		fmt.Fprintf(w, "%s\t%.0f\t%s\t%.1f\n", path, v.Count, v.Last.Local().Format("2006-01-02 15:04"), frecency[path])
+	}
+	w.Flush()
+}
+
+// forgetHistory drops the repositories named from the history. Like other
+// selectors, a name matches the end of a path.
+func forgetHistory(root string, history *config.History, names []string) {
+	if len(names) == 0 {
+		logrus.Fatal("Name the repositories to forget")
+	}
+	for _, name := range names {
+		name = strings.Trim(name, "/")
+		var found bool
+		for path := range history.Visits {
+			if path == name || strings.HasSuffix(path, "/"+name) {
+				history.Forget(path)
+				found = true
+			}
+		}
+		if !found {
+			logrus.WithField("repo", name).Warn("No repository in the history matches")
+		}
+	}
+	if err := history.Save(root); err != nil {
+		logrus.WithError(err).Fatal("Unable to save history")
+	}
+}
+
 func init() {
 	RootCmd.AddCommand(lsCmd)
 	lsCmd.PersistentFlags().IntVarP(&limit, "limit", "n", 0, "Limit the number of results returned (default is no limit)")
 	lsCmd.PersistentFlags().BoolVarP(&all, "all", "a", false, "Show all repositories, not just those in the manifest")
+	lsCmd.PersistentFlags().StringVar(&matcher, "matcher", match.Matchers[0], "How to match the search string: "+strings.Join(match.Matchers, ", "))
+	lsCmd.PersistentFlags().BoolVar(&refresh, "refresh", false, "Rescan the Jig root instead of trusting the index")
+	lsCmd.PersistentFlags().BoolVar(&stats, "stats", false, "Show the repositories in the history, most visited first")
+	lsCmd.PersistentFlags().BoolVar(&forget, "forget", false, "Drop the repositories named from the history")
+	lsCmd.PersistentFlags().BoolVarP(&interactive, "interactive", "i", false, "Choose a repository from the matches as you type")
+	lsCmd.PersistentFlags().StringVarP(&worktree, "worktree", "w", "", "List repositories in the named worktree set instead of the Jig root")
 }
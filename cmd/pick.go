@@ -0,0 +1,135 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/daemon"
+	"github.com/iancmcc/jig/match"
+	"github.com/iancmcc/jig/picker"
+	"github.com/iancmcc/jig/utils"
+	"github.com/iancmcc/jig/vcs"
+)
+
+// pickRepo lets the user choose one of paths, relative to base, showing the
+// branch of each and whether it's dirty. Statuses come from the daemon if
+// it's running; otherwise those in the index are shown while git checks
+// each repository again.
+func pickRepo(root, base string, paths []string, query string, rank func(m match.Matcher) []string) (string, error) {
+	tty, err := picker.OpenTTY()
+	if err != nil {
+		return "", err
+	}
+	defer tty.Close()
+
+	var mu sync.Mutex
+	stats := map[string]*vcs.Status{}
+	fresh := map[string]bool{}
+	if base == root {
+		if resp, err := daemon.Query(root, daemon.QueryStatus); err == nil {
+			for _, stat := range resp.Statuses {
+				stats[stat.Repo] = stat
+				fresh[stat.Repo] = true
+			}
+		}
+		if idx, err := config.DefaultIndex(root); err == nil {
+			for path, entry := range idx.Repos {
+				if _, ok := stats[path]; !ok && entry.Status != nil {
+					stats[path] = &vcs.Status{
+						Repo:      path,
+						Branch:    entry.Branch,
+						Staged:    entry.Status.Staged,
+						Unstaged:  entry.Status.Unstaged,
+						Untracked: entry.Status.Untracked,
+					}
+				}
+			}
+		}
+	}
+
+	updates := make(chan struct{}, 1)
+	done := make(chan struct{})
+	defer close(done)
+	if manifest, err := config.DefaultManifest(root); err == nil {
+		// Looking doesn't count as changing anything
+		os.Setenv("GIT_OPTIONAL_LOCKS", "0")
+		sem := make(chan struct{}, 4)
+		for _, repo := range manifest.Repos {
+			short, err := utils.RepoToPath(repo.Repo)
+			if err != nil {
+				continue
+			}
+			if fresh[short] {
+				continue
+			}
+			go func(repo *config.Repo, short string) {
+				select {
+				case sem <- struct{}{}:
+				case <-done:
+					return
+				}
+				defer func() { <-sem }()
+				dir := filepath.Join(base, short)
+				if _, err := os.Stat(dir); err != nil {
+					return
+				}
+				stat, err := vcs.Git.Status(repo, dir)
+				if err != nil {
+					logrus.WithError(err).WithField("repo", short).Debug("Unable to get status for repo")
+					return
+				}
+				mu.Lock()
+				stats[short] = stat
+				mu.Unlock()
+				select {
+				case updates <- struct{}{}:
+				default:
+				}
+			}(repo, short)
+		}
+	}
+
+	p := &picker.Picker{
+		Paths: paths,
+		Matcher: func(query string) match.Matcher {
+			// Checked before the picker started
+			m, _ := match.NewTokensMatcher(matcher, strings.Fields(query))
+			return m
+		},
+		Rank: rank,
+		Describe: func(path string) string {
+			mu.Lock()
+			stat, ok := stats[path]
+			mu.Unlock()
+			if !ok {
+				return ""
+			}
+			if stat.Staged || stat.Unstaged || stat.Untracked {
+				return stat.Branch + "*"
+			}
+			return stat.Branch
+		},
+		Updates: updates,
+		Query:   query,
+		Width:   tty.Width(),
+	}
+	return p.Run(tty, tty)
+}
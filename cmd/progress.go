@@ -0,0 +1,214 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cheggaaa/pb"
+	"github.com/iancmcc/jig/utils"
+	"github.com/iancmcc/jig/vcs"
+)
+
+const (
+	// refreshRate limits how often the live view is redrawn
+	refreshRate = 100 * time.Millisecond
+	// maxActiveLines limits how many repositories the live view shows at once
+	maxActiveLines = 20
+)
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// showProgress displays the progress of several tasks until they have all
+// finished. Terminals get a live view with a line per active repository;
+// anything else gets a timestamped line as each repository starts and
+// finishes. If an event stream was asked for, events are emitted along the
+// way, and stand in for the display when they go to stdout. It returns the
+// number of tasks that failed.
+func showProgress(tasks ...vcs.Task) int {
+	if sink := eventStream(); sink != nil {
+		var wait func()
+		tasks, wait = sink.tee(tasks)
+		defer wait()
+		if sink.stdout {
+			failed := 0
+			for o := range vcs.CombinedProgress(tasks...) {
+				failed = o.Failed
+			}
+			return failed
+		}
+	}
+	if isTerminal(os.Stdout) {
+		return liveProgress(os.Stdout, tasks)
+	}
+	return logProgress(os.Stdout, tasks)
+}
+
+// summary describes overall progress in a line
+func summary(o vcs.Overall) string {
+	line := fmt.Sprintf("%d/%d repos done  %3d%%", o.Done, len(o.Repos), int(o.Fraction*100))
+	if o.Failed > 0 {
+		line += fmt.Sprintf("  %d failed", o.Failed)
+	}
+	if o.Throughput > 0 {
+		line += fmt.Sprintf("  %s/s", utils.HumanBytes(int64(o.Throughput)))
+	}
+	if o.ETA > 0 {
+		line += fmt.Sprintf("  ETA %s", o.ETA.Round(time.Second))
+	}
+	return line
+}
+
+func logProgress(w io.Writer, tasks []vcs.Task) int {
+	stamp := func() string {
+		return time.Now().Format("15:04:05")
+	}
+	for _, t := range tasks {
+		fmt.Fprintf(w, "%s start  %s\n", stamp(), t.Repo)
+	}
+	done := make([]bool, len(tasks))
+	last := vcs.Overall{Fraction: 1}
+	for o := range vcs.CombinedProgress(tasks...) {
+		for i, rs := range o.Repos {
+			if rs.Done && !done[i] {
+				done[i] = true
+				elapsed := rs.Finished.Sub(rs.Started).Round(100 * time.Millisecond)
+				if rs.Err != nil {
+					fmt.Fprintf(w, "%s failed %s (%s): %s\n", stamp(), rs.Repo, elapsed, rs.Err)
+				} else {
+					fmt.Fprintf(w, "%s finish %s (%s)\n", stamp(), rs.Repo, elapsed)
+				}
+			}
+		}
+		last = o
+	}
+	line := fmt.Sprintf("%s %d repos done in %s", stamp(), len(tasks), last.Elapsed.Round(100*time.Millisecond))
+	if last.Failed > 0 {
+		line += fmt.Sprintf(", %d failed", last.Failed)
+	}
+	fmt.Fprintln(w, line)
+	return last.Failed
+}
+
+// frame redraws a block of lines in place on a terminal
+type frame struct {
+	w     io.Writer
+	lines int
+}
+
+// draw overwrites the previous block with out
+func (f *frame) draw(out []string) {
+	// Move back to the top of the previous frame and overwrite it
+	if f.lines > 0 {
+		fmt.Fprintf(f.w, "\x1b[%dA", f.lines)
+	}
+	for _, line := range out {
+		fmt.Fprintf(f.w, "\x1b[2K%s\n", line)
+	}
+	// Clear lines left over from a taller previous frame
+	for i := len(out); i < f.lines; i++ {
+		fmt.Fprint(f.w, "\x1b[2K\n")
+	}
+	if f.lines > len(out) {
+		fmt.Fprintf(f.w, "\x1b[%dA", f.lines-len(out))
+	}
+	f.lines = len(out)
+}
+
+func liveProgress(w io.Writer, tasks []vcs.Task) int {
+	repos := []string{}
+	for _, t := range tasks {
+		repos = append(repos, t.Repo)
+	}
+	var (
+		fr    = &frame{w: w}
+		drawn time.Time
+		last  = vcs.NewTracker(repos...).Snapshot()
+	)
+	draw := func(o vcs.Overall) {
+		fr.draw(liveLines(o))
+		drawn = time.Now()
+	}
+	draw(last)
+	for o := range vcs.CombinedProgress(tasks...) {
+		if o.Done != last.Done || time.Since(drawn) >= refreshRate {
+			draw(o)
+		}
+		last = o
+	}
+	draw(last)
+	for _, rs := range last.Repos {
+		if rs.Err != nil {
+			fmt.Fprintf(w, "  %s failed: %s\n", rs.Repo, rs.Err)
+		}
+	}
+	return last.Failed
+}
+
+// truncate cuts line down to at most width characters, without splitting
+// any of them
+func truncate(line string, width int) string {
+	if width > 0 && utf8.RuneCountInString(line) > width {
+		return string([]rune(line)[:width])
+	}
+	return line
+}
+
+// liveLines renders a frame of the live view
+func liveLines(o vcs.Overall) []string {
+	width, err := pb.GetTerminalWidth()
+	if err != nil || width <= 0 {
+		width = 80
+	}
+	var namelen int
+	for _, rs := range o.Repos {
+		if l := utf8.RuneCountInString(rs.Repo); !rs.Done && l > namelen {
+			namelen = l
+		}
+	}
+	lines := []string{}
+	for _, rs := range o.Repos {
+		if rs.Done {
+			continue
+		}
+		if len(lines) == maxActiveLines {
+			lines = append(lines, fmt.Sprintf("  ... and %d more", o.Active-maxActiveLines))
+			break
+		}
+		var status string
+		switch {
+		case rs.Message == "":
+			status = rs.Phase.String()
+		case rs.Total > 0:
+			status = fmt.Sprintf("%-20s %3d%%", rs.Message, rs.Current*100/rs.Total)
+		default:
+			status = fmt.Sprintf("%-20s %d", rs.Message, rs.Current)
+		}
+		elapsed := time.Since(rs.Started).Truncate(time.Second)
+		line := fmt.Sprintf("  %-*s  %-26s %s", namelen, rs.Repo, status, elapsed)
+		lines = append(lines, truncate(line, width-1))
+	}
+	return append(lines, summary(o))
+}
@@ -15,11 +15,9 @@
 package cmd
 
 import (
-	"fmt"
 	"path/filepath"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/cheggaaa/pb"
 	"github.com/iancmcc/jig/config"
 	"github.com/iancmcc/jig/utils"
 	"github.com/iancmcc/jig/vcs"
@@ -28,7 +26,7 @@ import (
 
 // pullCmd represents the pull command
 var pullCmd = &cobra.Command{
-	Use:     "pull",
+	Use:     "pull [repo...]",
 	Short:   "Update all repositories in your manifest",
 	Aliases: []string{"up"},
 	Run: func(cmd *cobra.Command, args []string) {
@@ -40,32 +38,31 @@ var pullCmd = &cobra.Command{
 		if err != nil {
 			logrus.Fatal("No repo manifest to use to pull. `jig restore` a manifest first.")
 		}
-		pullchans := []<-chan vcs.Progress{}
-		for _, repo := range manifest.Repos {
+		applySettings(root)
+		tasks := []vcs.Task{}
+		repos := selectRepos(manifest, args)
+		for _, repo := range repos {
 			dir, err := utils.RepoToPath(repo.Repo)
 			if err != nil {
 				logrus.WithField("repo", repo.Repo).Error("Unable to parse repo")
+				continue
 			}
 			log := logrus.WithField("repo", dir)
 			dir = filepath.Join(root, dir)
 			dir, err = filepath.Abs(dir)
 			if err != nil {
 				log.WithError(err).Error("Unable to pull repo")
+				continue
 			}
 			pullchan, err := vcs.Git.Pull(repo, dir)
 			if err != nil {
 				log.WithError(err).Error("Unable to pull repo")
+				continue
 			}
-			pullchans = append(pullchans, pullchan)
+			tasks = append(tasks, vcs.NewTask(repo.Repo, pullchan))
 		}
-		bar := pb.StartNew(0)
-		go bar.Start()
-		for prog := range vcs.CombinedProgress(pullchans...) {
-			bar.Total = int64(prog.Total)
-			bar.Set(prog.Current)
-			bar.Prefix(fmt.Sprintf("%s (%s)", prog.Message, prog.Repo))
-		}
-		bar.Finish()
+		showProgress(tasks...)
+		indexRepos(root, repos...)
 	},
 }
 
@@ -15,22 +15,26 @@
 package cmd
 
 import (
-	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/cheggaaa/pb"
 	"github.com/iancmcc/jig/config"
 	"github.com/iancmcc/jig/utils"
 	"github.com/iancmcc/jig/vcs"
 	"github.com/spf13/cobra"
 )
 
-var appnd, shallow bool
+var (
+	appnd, shallow bool
+	filter         string
+	sparse         []string
+)
 
 // restoreCmd represents the restore command
 var restoreCmd = &cobra.Command{
-	Use:   "restore",
+	Use:   "restore [manifest | repo...]",
 	Short: "A brief description of your command",
 	Long:  `A`,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -42,8 +46,32 @@ var restoreCmd = &cobra.Command{
 		if err != nil {
 			logrus.Fatal("No jig root found. Use 'jig init' to create one.")
 		}
-		if len(args) == 0 {
+		var (
+			bundles string
+			info    *bundleInfo
+			// existing is set when restoring the current manifest, and
+			// selectors pick repositories from it to restore
+			existing  bool
+			selectors []string
+		)
+		if fromBundle != "" {
+			if bundles, err = extractBundle(fromBundle); err != nil {
+				logrus.WithError(err).WithField("bundle", fromBundle).Fatal("Unable to read bundle")
+			}
+			defer os.RemoveAll(bundles)
+			if info, err = readBundleInfo(fromBundle); err != nil {
+				logrus.WithError(err).WithField("bundle", fromBundle).Fatal("Unable to read bundle")
+			}
+			var f *os.File
+			if f, err = os.Open(filepath.Join(bundles, bundleManifestName)); err != nil {
+				logrus.WithField("bundle", fromBundle).Fatal("Bundle has no manifest")
+			}
+			defer f.Close()
+			manifest, err = config.FromJSON(f)
+		} else if len(args) == 0 || !isManifestFile(args[0]) {
 			// Restore existing manifest
+			existing = true
+			selectors = args
 			manifest, err = config.DefaultManifest("")
 			if err != nil {
 				logrus.Fatal("No repo manifest to restore. Pass a manifest file first.")
@@ -76,14 +104,36 @@ var restoreCmd = &cobra.Command{
 				}
 				manifest = oldmanifest
 			}
+		} else if !existing {
+			if oldmanifest, err := config.JigRootManifest(); err == nil {
+				manifest.KeepAliases(oldmanifest)
+			}
 		}
 
 		manifest.Save(root)
+		settings := applySettings(root)
+		opts := vcs.CloneOptions{
+			Shallow: shallow,
+			Filter:  settings.Clone.Filter,
+			Sparse:  settings.Clone.Sparse,
+		}
+		if filter != "" {
+			opts.Filter = filter
+		}
+		if len(sparse) > 0 {
+			opts.Sparse = sparse
+		}
 
-		pullchans := []<-chan vcs.Progress{}
+		tasks := []vcs.Task{}
+		repos := selectRepos(manifest, selectors)
 
-		for _, repo := range manifest.Repos {
-			pullchan, err := vcs.ApplyRepoConfig(root, vcs.Git, repo, shallow)
+		for _, repo := range repos {
+			var pullchan <-chan vcs.Progress
+			if bundles != "" {
+				pullchan, err = restoreFromBundle(root, bundles, info, repo)
+			} else {
+				pullchan, err = vcs.ApplyRepoConfig(root, vcs.Git, repo, opts)
+			}
 			if err != nil {
 				short, e := utils.RepoToPath(repo.Repo)
 				if e != nil {
@@ -93,25 +143,31 @@ var restoreCmd = &cobra.Command{
 					"repo": short,
 					"ref":  repo.Ref,
 				}).Error("Unable to update repository")
+				continue
 			}
-			pullchans = append(pullchans, pullchan)
-		}
-
-		bar := pb.StartNew(0)
-		go bar.Start()
-
-		for prog := range vcs.CombinedProgress(pullchans...) {
-			bar.Total = int64(prog.Total)
-			bar.Set(prog.Current)
-			bar.Prefix(fmt.Sprintf("%s (%s)", prog.Message, prog.Repo))
+			tasks = append(tasks, vcs.NewTask(repo.Repo, pullchan))
 		}
 
-		bar.Finish()
+		showProgress(tasks...)
+		indexRepos(root, repos...)
 	},
 }
 
+// isManifestFile reports whether an argument to restore names a manifest to
+// read, rather than repositories in the current one
+func isManifestFile(arg string) bool {
+	if arg == "-" || strings.HasSuffix(arg, ".json") {
+		return true
+	}
+	info, err := os.Stat(arg)
+	return err == nil && !info.IsDir()
+}
+
 func init() {
 	RootCmd.AddCommand(restoreCmd)
 	restoreCmd.Flags().BoolVarP(&appnd, "append", "a", false, "Merge manifest being restored with current manifest")
 	restoreCmd.Flags().BoolVarP(&shallow, "shallow", "s", false, "Attempt to do shallow clones, and don't git flow initialize")
+	restoreCmd.Flags().StringVar(&filter, "filter", "", "Partial clone filter for repositories that don't set one, e.g. blob:none")
+	restoreCmd.Flags().StringVar(&fromBundle, "from-bundle", "", "Restore from an archive written by 'jig bundle create' instead of the network")
+	restoreCmd.Flags().StringSliceVar(&sparse, "sparse", nil, "Sparse checkout directories for repositories that don't set their own")
 }
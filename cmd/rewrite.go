@@ -0,0 +1,147 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/spf13/cobra"
+)
+
+// loadSettings finds the Jig root and loads its settings
+func loadSettings() (string, *config.Settings) {
+	root, err := config.FindClosestJigRoot("")
+	if err != nil {
+		logrus.Fatal("No jig root found. Use 'jig init' to create one.")
+	}
+	settings, err := config.DefaultSettings(root)
+	if err != nil {
+		logrus.WithError(err).Fatal("Unable to load jig settings")
+	}
+	return root, settings
+}
+
+func saveSettings(root string, settings *config.Settings) {
+	if err := settings.Save(root); err != nil {
+		logrus.WithError(err).Fatal("Unable to save jig settings")
+	}
+}
+
+// rewriteCmd represents the rewrite command
+var rewriteCmd = &cobra.Command{
+	Use:   "rewrite",
+	Short: "Manage rules that change the URLs repositories are cloned from",
+	Long: `Rewrite rules change the URL a repository is cloned and fetched from without
+changing where it is checked out, so a manifest can name https URLs while
+developers clone over ssh and CI clones from a local mirror. Prefix rules are
+applied first, then hosts that prefer ssh are switched to it. Run without a
+subcommand to list the rules; 'jig status -v' shows the URL each repository
+ends up with.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, settings := loadSettings()
+		rw := settings.Rewrite
+		prefixes := []string{}
+		for from := range rw.Prefixes {
+			prefixes = append(prefixes, from)
+		}
+		sort.Strings(prefixes)
+		w := tabwriter.NewWriter(os.Stdout, 0, 5, 4, ' ', 0)
+		fmt.Fprintf(w, "From\tTo\n")
+		for _, from := range prefixes {
+			fmt.Fprintf(w, "%s\t%s\n", from, rw.Prefixes[from])
+		}
+		for _, host := range rw.PreferSSH {
+			fmt.Fprintf(w, "%s\tssh\n", host)
+		}
+		w.Flush()
+	},
+}
+
+var rewriteAddCmd = &cobra.Command{
+	Use:   "add <from> <to>",
+	Short: "Clone URLs starting with one prefix from another instead",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			logrus.Fatal("Give the prefix to replace and the prefix to replace it with")
+		}
+		root, settings := loadSettings()
+		if settings.Rewrite.Prefixes == nil {
+			settings.Rewrite.Prefixes = map[string]string{}
+		}
+		settings.Rewrite.Prefixes[args[0]] = args[1]
+		saveSettings(root, settings)
+	},
+}
+
+var rewriteSSHCmd = &cobra.Command{
+	Use:   "ssh <host>...",
+	Short: "Clone https and git URLs for hosts over ssh instead",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			logrus.Fatal("Give at least one host to prefer ssh for")
+		}
+		root, settings := loadSettings()
+	hosts:
+		for _, host := range args {
+			for _, h := range settings.Rewrite.PreferSSH {
+				if strings.EqualFold(h, host) {
+					continue hosts
+				}
+			}
+			settings.Rewrite.PreferSSH = append(settings.Rewrite.PreferSSH, host)
+		}
+		saveSettings(root, settings)
+	},
+}
+
+var rewriteRemoveCmd = &cobra.Command{
+	Use:     "remove <from|host>...",
+	Aliases: []string{"rm"},
+	Short:   "Remove rewrite rules for prefixes or hosts",
+	Run: func(cmd *cobra.Command, args []string) {
+		root, settings := loadSettings()
+		for _, arg := range args {
+			var found bool
+			if _, ok := settings.Rewrite.Prefixes[arg]; ok {
+				delete(settings.Rewrite.Prefixes, arg)
+				found = true
+			}
+			hosts := settings.Rewrite.PreferSSH[:0]
+			for _, h := range settings.Rewrite.PreferSSH {
+				if strings.EqualFold(h, arg) {
+					found = true
+					continue
+				}
+				hosts = append(hosts, h)
+			}
+			settings.Rewrite.PreferSSH = hosts
+			if !found {
+				logrus.WithField("rule", arg).Warn("No rewrite rule to remove")
+			}
+		}
+		saveSettings(root, settings)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(rewriteCmd)
+	rewriteCmd.AddCommand(rewriteAddCmd, rewriteSSHCmd, rewriteRemoveCmd)
+}
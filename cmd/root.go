@@ -15,12 +15,16 @@
 package cmd
 
 import (
+	"strings"
+
 	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/vcs"
 	"github.com/spf13/cobra"
 )
 
 var (
 	verbose bool
+	offline bool
 )
 
 // RootCmd represents the base command when called without any subcommands
@@ -32,6 +36,8 @@ var RootCmd = &cobra.Command{
 		if verbose {
 			logrus.SetLevel(logrus.DebugLevel)
 		}
+		vcs.Git.Offline = offline
+		commandName = strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
 	},
 }
 
@@ -45,4 +51,6 @@ func Execute() {
 
 func init() {
 	RootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	RootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "Never touch the network; work from what has already been fetched")
+	RootCmd.PersistentFlags().StringVar(&eventsDest, "events", "", "Emit progress as JSON Lines to - (stdout), fd:N, unix:PATH or a file")
 }
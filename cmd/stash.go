@@ -0,0 +1,291 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/utils"
+	"github.com/iancmcc/jig/vcs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	stashUntracked bool
+)
+
+// stashSetup finds the Jig root and loads its named stashes
+func stashSetup() (string, *config.Stashes) {
+	root, err := config.FindClosestJigRoot("")
+	if err != nil {
+		logrus.Fatal("No jig root found. Use 'jig init' to create one.")
+	}
+	stashes, err := config.DefaultStashes(root)
+	if err != nil {
+		logrus.WithError(err).Fatal("Unable to load stashes")
+	}
+	return root, stashes
+}
+
+// stashCmd represents the stash command
+var stashCmd = &cobra.Command{
+	Use:   "stash",
+	Short: "Stash changes across repositories under a shared name",
+	Long: `Stash the uncommitted changes in every repository under one name, so they can
+be set aside and brought back together. Run without a subcommand to list the
+named stashes.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		stashListCmd.Run(cmd, args)
+	},
+}
+
+var stashSaveCmd = &cobra.Command{
+	Use:   "save <name> [repo...]",
+	Short: "Stash the changes in every dirty repository under a name",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			logrus.Fatal("Must pass a name for the stash")
+		}
+		name := args[0]
+		root, stashes := stashSetup()
+		if stashes.Get(name) != nil {
+			logrus.WithField("stash", name).Fatal("A stash with that name already exists")
+		}
+		manifest, err := config.DefaultManifest("")
+		if err != nil {
+			logrus.Fatal("No repo manifest to use. `jig restore` a manifest first.")
+		}
+		stash := &config.Stash{
+			Name:    name,
+			Created: time.Now().UTC(),
+			Repos:   []*config.StashedRepo{},
+		}
+		var (
+			wg     sync.WaitGroup
+			mu     sync.Mutex
+			failed bool
+		)
+		repos := selectRepos(manifest, args[1:])
+		for _, repo := range repos {
+			short, err := utils.RepoToPath(repo.Repo)
+			if err != nil {
+				logrus.WithField("repo", repo.Repo).Error("Unable to parse repo")
+				continue
+			}
+			dir := filepath.Join(root, short)
+			if _, err := os.Stat(dir); err != nil {
+				continue
+			}
+			wg.Add(1)
+			go func(repo *config.Repo, short, dir string) {
+				defer wg.Done()
+				commit, err := vcs.Git.StashSave(repo, dir, "jig: "+name, stashUntracked)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					logrus.WithError(err).WithField("repo", short).Error("Unable to stash changes")
+					failed = true
+					return
+				}
+				if commit == "" {
+					return
+				}
+				branch, _, _ := vcs.Git.Branch(repo, dir)
+				stash.Repos = append(stash.Repos, &config.StashedRepo{
+					Repo:   short,
+					Commit: commit,
+					Branch: string(branch),
+				})
+			}(repo, short, dir)
+		}
+		wg.Wait()
+		indexRepos(root, repos...)
+		sort.Slice(stash.Repos, func(i, j int) bool { return stash.Repos[i].Repo < stash.Repos[j].Repo })
+		if len(stash.Repos) == 0 {
+			fmt.Println("No local changes to stash")
+		} else {
+			stashes.Stashes = append(stashes.Stashes, stash)
+			if err := stashes.Save(root); err != nil {
+				logrus.WithError(err).Fatal("Unable to save stashes")
+			}
+			for _, s := range stash.Repos {
+				fmt.Printf("Stashed changes in %s\n", s.Repo)
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+var stashListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List the named stashes",
+	Run: func(cmd *cobra.Command, args []string) {
+		_, stashes := stashSetup()
+		w := tabwriter.NewWriter(os.Stdout, 0, 5, 4, ' ', 0)
+		fmt.Fprintf(w, "Name\tCreated\tRepos\n")
+		for _, stash := range stashes.Stashes {
+			repos := []string{}
+			for _, s := range stash.Repos {
+				repos = append(repos, fmt.Sprintf("%s (%s)", s.Repo, s.Branch))
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", stash.Name, stash.Created.Local().Format("2006-01-02 15:04"), strings.Join(repos, ", "))
+		}
+		w.Flush()
+	},
+}
+
+var stashPopCmd = &cobra.Command{
+	Use:   "pop <name>",
+	Short: "Restore the changes stashed under a name",
+	Long: `Apply the changes stashed under a name to each repository and drop them.
+Repositories where the changes don't apply cleanly are reported, and keep
+their stash so the pop can be finished by hand or retried.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			logrus.Fatal("Must pass the name of the stash to pop")
+		}
+		root, stashes := stashSetup()
+		stash := stashes.Get(args[0])
+		if stash == nil {
+			logrus.WithField("stash", args[0]).Fatal("No stash with that name")
+		}
+		manifest, err := config.DefaultManifest("")
+		if err != nil {
+			logrus.Fatal("No repo manifest to use. `jig restore` a manifest first.")
+		}
+		repos := map[string]*config.Repo{}
+		for _, r := range manifest.Repos {
+			if short, err := utils.RepoToPath(r.Repo); err == nil {
+				repos[short] = r
+			}
+		}
+		var (
+			wg   sync.WaitGroup
+			mu   sync.Mutex
+			kept = []*config.StashedRepo{}
+		)
+		for _, s := range stash.Repos {
+			wg.Add(1)
+			go func(s *config.StashedRepo) {
+				defer wg.Done()
+				repo := repos[s.Repo]
+				if repo == nil {
+					// The manifest only matters for logging
+					repo = &config.Repo{Repo: s.Repo}
+				}
+				err := vcs.Git.StashPop(repo, filepath.Join(root, s.Repo), s.Commit)
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case errors.Is(err, vcs.ErrStashConflict):
+					fmt.Printf("Conflicts in %s; the stash is kept until 'jig stash drop %s %s'\n", s.Repo, stash.Name, s.Repo)
+					kept = append(kept, s)
+				case err != nil:
+					fmt.Printf("Unable to restore %s: %s\n", s.Repo, err)
+					kept = append(kept, s)
+				default:
+					fmt.Printf("Restored changes in %s\n", s.Repo)
+				}
+			}(s)
+		}
+		wg.Wait()
+		popped := []*config.Repo{}
+		for _, s := range stash.Repos {
+			if repo := repos[s.Repo]; repo != nil {
+				popped = append(popped, repo)
+			}
+		}
+		indexRepos(root, popped...)
+		if len(kept) == 0 {
+			stashes.Remove(stash.Name)
+		} else {
+			sort.Slice(kept, func(i, j int) bool { return kept[i].Repo < kept[j].Repo })
+			stash.Repos = kept
+		}
+		if err := stashes.Save(root); err != nil {
+			logrus.WithError(err).Fatal("Unable to save stashes")
+		}
+		if len(kept) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+var stashDropCmd = &cobra.Command{
+	Use:   "drop <name> [repo...]",
+	Short: "Throw away the changes stashed under a name",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			logrus.Fatal("Must pass the name of the stash to drop")
+		}
+		root, stashes := stashSetup()
+		stash := stashes.Get(args[0])
+		if stash == nil {
+			logrus.WithField("stash", args[0]).Fatal("No stash with that name")
+		}
+		drop := map[string]struct{}{}
+		for _, sel := range args[1:] {
+			sel = strings.Trim(sel, "/")
+			var found bool
+			for _, s := range stash.Repos {
+				if s.Repo == sel || strings.HasSuffix(s.Repo, "/"+sel) {
+					drop[s.Repo] = struct{}{}
+					found = true
+				}
+			}
+			if !found {
+				logrus.WithField("repo", sel).Fatal("No repository in the stash matches")
+			}
+		}
+		kept := []*config.StashedRepo{}
+		for _, s := range stash.Repos {
+			if _, ok := drop[s.Repo]; len(drop) > 0 && !ok {
+				kept = append(kept, s)
+				continue
+			}
+			repo := &config.Repo{Repo: s.Repo}
+			if err := vcs.Git.StashDrop(repo, filepath.Join(root, s.Repo), s.Commit); err != nil {
+				logrus.WithError(err).WithField("repo", s.Repo).Warn("Unable to drop git stash")
+			}
+		}
+		if len(kept) == 0 {
+			stashes.Remove(stash.Name)
+		} else {
+			stash.Repos = kept
+		}
+		if err := stashes.Save(root); err != nil {
+			logrus.WithError(err).Fatal("Unable to save stashes")
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(stashCmd)
+	stashCmd.AddCommand(stashSaveCmd, stashListCmd, stashPopCmd, stashDropCmd)
+	stashSaveCmd.Flags().BoolVarP(&stashUntracked, "include-untracked", "u", false, "Stash untracked files too")
+}
@@ -15,27 +15,278 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"text/tabwriter"
+	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/cheggaaa/pb"
 	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/daemon"
+	"github.com/iancmcc/jig/fs"
 	"github.com/iancmcc/jig/utils"
 	"github.com/iancmcc/jig/vcs"
 	"github.com/spf13/cobra"
 )
 
+const (
+	// watchDebounce is how long a repository has to be quiet after changing
+	// before its status is recomputed
+	watchDebounce = 250 * time.Millisecond
+	// watchPoll is how often repositories that can't be watched completely
+	// have their status recomputed instead
+	watchPoll = 5 * time.Second
+)
+
 var (
-	statall bool
+	statall     bool
+	statwatch   bool
+	statrefresh bool
 )
 
+// statusTable writes a table of statuses. Repositories that are both changed
+// and off their manifest ref come first, then changed ones, then branched
+// ones, and unchanged ones only with --all. If changed is non-nil, a column
+// shows when each repository last changed.
+func statusTable(w io.Writer, stats []*vcs.Status, changed map[string]time.Time) {
+	group := func(stat *vcs.Status) int {
+		ischanged := stat.Staged || stat.Unstaged || stat.Untracked
+		isbranched := stat.Branch != stat.OrigRef
+		switch {
+		case isbranched && ischanged:
+			return 0
+		case ischanged:
+			return 1
+		case isbranched:
+			return 2
+		}
+		return 3
+	}
+	sorted := []*vcs.Status{}
+	for _, stat := range stats {
+		if statall || group(stat) < 3 {
+			sorted = append(sorted, stat)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		gi, gj := group(sorted[i]), group(sorted[j])
+		if gi != gj {
+			return gi < gj
+		}
+		return sorted[i].Repo < sorted[j].Repo
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 5, 4, ' ', 0)
+	header := "Repo\tRef (Orig)\tStaged\tUnstaged\tUntracked"
+	if changed != nil {
+		header += "\tChanged"
+	}
+	if verbose {
+		header += "\tURL"
+	}
+	fmt.Fprintln(tw, header)
+	for _, stat := range sorted {
+		var (
+			unstaged, untracked, staged string
+		)
+		if stat.Staged {
+			staged = "*"
+		}
+		if stat.Unstaged {
+			unstaged = "*"
+		}
+		if stat.Untracked {
+			untracked = "*"
+		}
+		var orig string
+		if stat.Branch != stat.OrigRef {
+			orig = fmt.Sprintf(" (%s)", stat.OrigRef)
+		}
+		shape := []string{}
+		if stat.Shallow {
+			shape = append(shape, "shallow")
+		}
+		if stat.Partial {
+			shape = append(shape, "partial")
+		}
+		if stat.Sparse {
+			shape = append(shape, "sparse")
+		}
+		if stat.Ahead > 0 {
+			shape = append(shape, fmt.Sprintf("ahead %d", stat.Ahead))
+		}
+		if stat.Behind > 0 {
+			shape = append(shape, fmt.Sprintf("behind %d", stat.Behind))
+		}
+		if len(shape) > 0 {
+			orig += fmt.Sprintf(" [%s]", strings.Join(shape, ","))
+		}
+		line := fmt.Sprintf("%s\t%s%s\t%s\t%s\t%s", stat.Repo, stat.Branch, orig, staged, unstaged, untracked)
+		if changed != nil {
+			var when string
+			if t, ok := changed[stat.Repo]; ok {
+				when = t.Format("15:04:05")
+			}
+			line += "\t" + when
+		}
+		if verbose {
+			line += "\t" + stat.URL
+		}
+		fmt.Fprintln(tw, line)
+	}
+	tw.Flush()
+}
+
+// repoStatus gets the status of a repository checked out below root
+func repoStatus(root string, repo *config.Repo) (*vcs.Status, error) {
+	dir, err := utils.RepoToPath(repo.Repo)
+	if err != nil {
+		return nil, err
+	}
+	dir, err = filepath.Abs(filepath.Join(root, dir))
+	if err != nil {
+		return nil, err
+	}
+	return vcs.Git.Status(repo, dir)
+}
+
+// watchStatus keeps a table of statuses on screen, recomputing the status of
+// each repository when something in it changes. Repositories that can't be
+// watched completely are checked every so often instead.
+func watchStatus(root string, repos []*config.Repo, stats []*vcs.Status) {
+	// Our own git status shouldn't rewrite the index, which would look like
+	// another change
+	os.Setenv("GIT_OPTIONAL_LOCKS", "0")
+	watcher, err := fs.NewRepoWatcher(watchDebounce)
+	if err != nil {
+		logrus.WithError(err).Fatal("Unable to watch repositories")
+	}
+	defer watcher.Close()
+
+	bydir := map[string]*config.Repo{}
+	current := map[string]*vcs.Status{}
+	for _, stat := range stats {
+		current[stat.Repo] = stat
+	}
+	for _, repo := range repos {
+		short, err := utils.RepoToPath(repo.Repo)
+		if err != nil {
+			continue
+		}
+		if _, ok := current[short]; !ok {
+			continue
+		}
+		dir := filepath.Join(root, short)
+		bydir[dir] = repo
+		if err := watcher.Watch(dir); err != nil {
+			logrus.WithError(err).WithField("repo", repo.Repo).Warn("Unable to watch all of repo; checking it every " + watchPoll.String())
+		}
+	}
+	// polled counts the repositories the watcher can't be trusted with
+	polled := func() int {
+		n := 0
+		for dir := range bydir {
+			if !watcher.Complete(dir) {
+				n++
+			}
+		}
+		return n
+	}
+
+	changed := map[string]time.Time{}
+	live := isTerminal(os.Stdout)
+	fr := &frame{w: os.Stdout}
+	draw := func() {
+		stats := []*vcs.Status{}
+		for _, stat := range current {
+			stats = append(stats, stat)
+		}
+		var buf bytes.Buffer
+		statusTable(&buf, stats, changed)
+		if !live {
+			buf.WriteString("\n")
+			os.Stdout.Write(buf.Bytes())
+			return
+		}
+		width, err := pb.GetTerminalWidth()
+		if err != nil || width <= 0 {
+			width = 80
+		}
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		for i, line := range lines {
+			lines[i] = truncate(line, width-1)
+		}
+		footer := fmt.Sprintf("Watching %d repos", len(bydir))
+		if n := polled(); n > 0 {
+			footer += fmt.Sprintf(", checking %d of them every %s", n, watchPoll)
+		}
+		lines = append(lines, "", footer+". Press Ctrl-C to stop.")
+		fr.draw(lines)
+	}
+	// update recomputes the status of the repository in dir, reporting
+	// whether it counts as changed. Only a watched change counts when the
+	// status comes out the same.
+	update := func(dir string, watched bool) bool {
+		repo := bydir[dir]
+		stat, err := repoStatus(root, repo)
+		if err != nil {
+			logrus.WithError(err).WithField("repo", repo.Repo).Debug("Unable to get status for repo")
+			return false
+		}
+		if prev := current[stat.Repo]; !watched && prev != nil && *prev == *stat {
+			return false
+		}
+		current[stat.Repo] = stat
+		changed[stat.Repo] = time.Now()
+		saveStatus(root, map[string]string{stat.Repo: repo.Repo}, stat)
+		return true
+	}
+	poll := time.NewTicker(watchPoll)
+	defer poll.Stop()
+	draw()
+	for {
+		select {
+		case dir, ok := <-watcher.Changes:
+			if !ok {
+				return
+			}
+			if update(dir, true) {
+				draw()
+			}
+		case <-poll.C:
+			redraw := false
+			for dir := range bydir {
+				if !watcher.Complete(dir) && update(dir, false) {
+					redraw = true
+				}
+			}
+			if redraw {
+				draw()
+			}
+		case err := <-watcher.Errors:
+			logrus.WithError(err).Debug("Error watching repositories")
+		}
+	}
+}
+
 // statusCmd represents the status command
 var statusCmd = &cobra.Command{
-	Use:   "status",
+	Use:   "status [repo...]",
 	Short: "Print status of the repositories in your manifest",
+	Long: `Print the status of the repositories in your manifest. Statuses come from the
+daemon if it's running. Otherwise each repository is checked with git status,
+and its branch and how far ahead or behind it is come from the index, which
+jig updates whenever it changes a repository, unless git has changed them
+since. Use --refresh to check everything with git again. With --watch, the
+table stays on screen and each repository's status is updated as files in it
+change.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		root, err := config.FindClosestJigRoot("")
 		if err != nil {
@@ -45,101 +296,74 @@ var statusCmd = &cobra.Command{
 		if err != nil {
 			return
 		}
-		statuschan := make(chan *vcs.Status)
+		applySettings(root)
+		idx := loadIndex(root, statrefresh)
 		var (
-			wg     sync.WaitGroup
-			maxlen int
+			wg    sync.WaitGroup
+			mu    sync.Mutex
+			stats = []*vcs.Status{}
+			seen  = []*vcs.Status{}
+			uris  = map[string]string{}
+			fresh = map[string]*vcs.Status{}
 		)
-		for _, r := range manifest.Repos {
-			dir, err := utils.RepoToPath(r.Repo)
-			if err != nil {
-				logrus.WithField("repo", r.Repo).Error("Unable to parse repo")
-				continue
+		if !statrefresh && !statwatch {
+			if resp, err := daemon.Query(root, daemon.QueryStatus); err == nil {
+				logrus.Debug("Using statuses from the daemon")
+				for _, stat := range resp.Statuses {
+					fresh[stat.Repo] = stat
+				}
 			}
-			l := len(dir)
-			if maxlen < l {
-				maxlen = l
+		}
+		repos := selectRepos(manifest, args)
+		for _, r := range repos {
+			var indexed *vcs.Status
+			// Watching starts from what's there now
+			if !statrefresh && !statwatch {
+				if short, err := utils.RepoToPath(r.Repo); err == nil && fresh[short] != nil {
+					stats = append(stats, fresh[short])
+					continue
+				}
+				indexed = indexedStatus(root, idx, r)
 			}
 			wg.Add(1)
-			go func(repo *config.Repo, dir string) {
+			go func(repo *config.Repo, indexed *vcs.Status) {
 				defer wg.Done()
-				log := logrus.WithField("repo", repo.Repo)
-				dir = filepath.Join(root, dir)
-				dir, err = filepath.Abs(dir)
-				if err != nil {
-					log.WithError(err).Error("Unable to get status for repo")
-					return
+				if indexed != nil {
+					// Files can change without jig knowing, so only git
+					// can say whether the repository is dirty
+					err := vcs.Git.Dirty(repo, filepath.Join(root, indexed.Repo), indexed)
+					if err == nil {
+						mu.Lock()
+						stats = append(stats, indexed)
+						mu.Unlock()
+						return
+					}
 				}
-				stat, err := vcs.Git.Status(repo, dir)
+				stat, err := repoStatus(root, repo)
 				if err != nil {
-					log.WithError(err).Error("Unable to get status for repo")
+					logrus.WithError(err).WithField("repo", repo.Repo).Error("Unable to get status for repo")
 					return
 				}
-				statuschan <- stat
-			}(r, dir)
+				mu.Lock()
+				stats = append(stats, stat)
+				seen = append(seen, stat)
+				uris[stat.Repo] = repo.Repo
+				mu.Unlock()
+			}(r, indexed)
 		}
-		go func() {
-			wg.Wait()
-			close(statuschan)
-		}()
-
-		w := tabwriter.NewWriter(os.Stdout, 0, 5, 4, ' ', 0)
-		fmt.Fprintf(w, "Repo\tRef (Orig)\tStaged\tUnstaged\tUntracked\n")
-		branched := []*vcs.Status{}
-		changed := []*vcs.Status{}
-		ordinary := []*vcs.Status{}
-		print := func(stat *vcs.Status) {
-			var (
-				unstaged, untracked, staged string
-			)
-			if stat.Staged {
-				staged = "*"
-			}
-			if stat.Unstaged {
-				unstaged = "*"
-			}
-			if stat.Untracked {
-				untracked = "*"
-			}
-			var orig string
-			if stat.Branch != stat.OrigRef {
-				orig = fmt.Sprintf(" (%s)", stat.OrigRef)
-			}
-			fmt.Fprintf(w, "%s\t%s%s\t%s\t%s\t%s\n", stat.Repo, stat.Branch, orig, staged, unstaged, untracked)
-		}
-		for stat := range statuschan {
-			ischanged := stat.Staged || stat.Unstaged || stat.Untracked
-			isbranched := stat.Branch != stat.OrigRef
-			if isbranched && ischanged {
-				print(stat)
-				continue
-			}
-			if isbranched {
-				branched = append(branched, stat)
-				continue
-			}
-			if ischanged {
-				changed = append(changed, stat)
-				continue
-			}
-			ordinary = append(ordinary, stat)
-		}
-		for _, stat := range changed {
-			print(stat)
-		}
-		for _, stat := range branched {
-			print(stat)
-		}
-		if statall {
-			for _, stat := range ordinary {
-				print(stat)
-			}
+		wg.Wait()
+		saveStatus(root, uris, seen...)
+		if statwatch {
+			watchStatus(root, repos, stats)
+			return
 		}
-		w.Flush()
+		statusTable(os.Stdout, stats, nil)
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(statusCmd)
 	statusCmd.PersistentFlags().BoolVarP(&statall, "all", "a", false, "Show status for all repositories, not just those with chnages")
+	statusCmd.Flags().BoolVar(&statrefresh, "refresh", false, "Rescan the Jig root and check every repository with git, instead of using the index")
+	statusCmd.Flags().BoolVarP(&statwatch, "watch", "w", false, "Keep the table on screen, updating it as repositories change")
 }
@@ -0,0 +1,113 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/utils"
+	"github.com/iancmcc/jig/vcs"
+)
+
+// objectCache returns the object cache configured for a Jig root, or nil if
+// caching is disabled
+func objectCache(root string, settings *config.Settings) (*vcs.ObjectCache, error) {
+	if !settings.Cache.Enabled {
+		return nil, nil
+	}
+	dir := settings.Cache.Dir
+	if dir == "" {
+		d, err := vcs.DefaultCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = d
+	} else if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	return &vcs.ObjectCache{Dir: dir}, nil
+}
+
+// applySettings configures the VCS drivers from the settings of a Jig root,
+// and returns those settings
+func applySettings(root string) *config.Settings {
+	settings, err := config.DefaultSettings(root)
+	if err != nil {
+		logrus.WithError(err).Warn("Unable to load jig settings; using defaults")
+		return &config.Settings{}
+	}
+	vcs.Git.Rewrite = settings.Rewrite
+	cache, err := objectCache(root, settings)
+	if err != nil {
+		logrus.WithError(err).Warn("Unable to locate object cache; cloning without it")
+		return settings
+	}
+	vcs.Git.Cache = cache
+	return settings
+}
+
+// repoDir returns the absolute path a repository is checked out to
+func repoDir(root string, r *config.Repo) (string, error) {
+	dir, err := utils.RepoToPath(r.Repo)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(filepath.Join(root, dir))
+}
+
+// selectRepos returns the repositories in the manifest matching the
+// selectors passed on the command line, or all of them if there are none. A
+// selector matches a repository's path, or any trailing part of it, such as
+// "owner/repo" or "repo". A selector that's an alias of a repository selects
+// only that one.
+func selectRepos(manifest *config.Manifest, selectors []string) []*config.Repo {
+	if len(selectors) == 0 {
+		return manifest.Repos
+	}
+	selected := []*config.Repo{}
+	seen := map[*config.Repo]struct{}{}
+	for _, sel := range selectors {
+		sel = strings.Trim(sel, "/")
+		if r := manifest.Alias(sel); r != nil {
+			if _, ok := seen[r]; !ok {
+				seen[r] = struct{}{}
+				selected = append(selected, r)
+			}
+			continue
+		}
+		var found bool
+		for _, r := range manifest.Repos {
+			short, err := utils.RepoToPath(r.Repo)
+			if err != nil {
+				continue
+			}
+			if short != sel && !strings.HasSuffix(short, "/"+sel) {
+				continue
+			}
+			found = true
+			if _, ok := seen[r]; !ok {
+				seen[r] = struct{}{}
+				selected = append(selected, r)
+			}
+		}
+		if !found {
+			logrus.WithField("repo", sel).Fatal("No repository in the manifest matches")
+		}
+	}
+	return selected
+}
@@ -0,0 +1,207 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/fs"
+	"github.com/iancmcc/jig/utils"
+	"github.com/iancmcc/jig/vcs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	worktreeBranch string
+	worktreeForce  bool
+)
+
+// worktreeRepos returns the paths, relative to the worktree set, of the
+// repositories checked out in a worktree set
+func worktreeRepos(setdir string) []string {
+	repos := []string{}
+	for dir := range fs.DefaultFinder().FindBelowWithChildrenNamed(setdir, ".git", 1) {
+		if rel, err := filepath.Rel(setdir, dir); err == nil {
+			repos = append(repos, rel)
+		}
+	}
+	return repos
+}
+
+// worktreeSetDir returns the directory of the named worktree set, refusing
+// names that would put it anywhere but directly under the worktrees directory
+func worktreeSetDir(root, name string) string {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/ \t"+string(filepath.Separator)) {
+		logrus.WithField("set", name).Fatal("Worktree set names must be a single directory name, without slashes or spaces")
+	}
+	return config.WorktreeRoot(root, name)
+}
+
+// removeEmptyParents removes dir and its parents, up to but not including
+// stop, for as long as they are empty
+func removeEmptyParents(dir, stop string) {
+	for dir != stop && len(dir) > len(stop) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// worktreeCmd represents the worktree command
+var worktreeCmd = &cobra.Command{
+	Use:   "worktree",
+	Short: "Manage sets of git worktrees for working on several branches at once",
+	Long: `A worktree set is a tree of git worktrees, laid out like the Jig root, with
+each repository checked out on the same branch. Use 'jig ls -w <name>' to
+navigate within a set.`,
+}
+
+var worktreeAddCmd = &cobra.Command{
+	Use:   "add <name> [repo...]",
+	Short: "Create worktrees for repositories in a named worktree set",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			logrus.Fatal("Must pass a name for the worktree set")
+		}
+		root, err := config.FindClosestJigRoot("")
+		if err != nil {
+			logrus.Fatal("No jig root found. Use 'jig init' to create one.")
+		}
+		manifest, err := config.DefaultManifest("")
+		if err != nil {
+			logrus.Fatal("No repo manifest to use. `jig restore` a manifest first.")
+		}
+		name := args[0]
+		branch := worktreeBranch
+		if branch == "" {
+			branch = name
+		}
+		setdir := worktreeSetDir(root, name)
+		var wg sync.WaitGroup
+		for _, r := range selectRepos(manifest, args[1:]) {
+			short, err := utils.RepoToPath(r.Repo)
+			if err != nil {
+				logrus.WithField("repo", r.Repo).Error("Unable to parse repo")
+				continue
+			}
+			dir := filepath.Join(root, short)
+			path := filepath.Join(setdir, short)
+			log := logrus.WithField("repo", short)
+			if _, err := os.Stat(path); err == nil {
+				log.Info("Worktree already exists")
+				continue
+			}
+			wg.Add(1)
+			go func(r *config.Repo) {
+				defer wg.Done()
+				if err := vcs.Git.AddWorktree(r, dir, path, branch); err != nil {
+					log.WithError(err).Error("Unable to add worktree")
+				}
+			}(r)
+		}
+		wg.Wait()
+	},
+}
+
+var worktreeListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List worktree sets and the branches checked out in them",
+	Aliases: []string{"ls"},
+	Run: func(cmd *cobra.Command, args []string) {
+		root, err := config.FindClosestJigRoot("")
+		if err != nil {
+			logrus.Fatal("No jig root found. Use 'jig init' to create one.")
+		}
+		sets, err := ioutil.ReadDir(config.WorktreesDir(root))
+		if err != nil && !os.IsNotExist(err) {
+			logrus.WithError(err).Fatal("Unable to read worktree sets")
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 5, 4, ' ', 0)
+		fmt.Fprintf(w, "Set\tRepo\tBranch\n")
+		for _, set := range sets {
+			if !set.IsDir() {
+				continue
+			}
+			setdir := config.WorktreeRoot(root, set.Name())
+			for _, rel := range worktreeRepos(setdir) {
+				br, _, err := vcs.Git.Branch(&config.Repo{Repo: rel}, filepath.Join(setdir, rel))
+				if err != nil {
+					br = []byte("?")
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", set.Name(), rel, br)
+			}
+		}
+		w.Flush()
+	},
+}
+
+var worktreeRemoveCmd = &cobra.Command{
+	Use:     "remove <name> [repo...]",
+	Short:   "Remove worktrees from a named worktree set",
+	Aliases: []string{"rm"},
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			logrus.Fatal("Must pass the name of the worktree set")
+		}
+		root, err := config.FindClosestJigRoot("")
+		if err != nil {
+			logrus.Fatal("No jig root found. Use 'jig init' to create one.")
+		}
+		setdir := worktreeSetDir(root, args[0])
+		if _, err := os.Stat(setdir); err != nil {
+			logrus.WithField("set", args[0]).Fatal("No such worktree set")
+		}
+		selected := map[string]struct{}{}
+		if len(args) > 1 {
+			manifest, err := config.DefaultManifest("")
+			if err != nil {
+				logrus.Fatal("No repo manifest to select repositories from")
+			}
+			for _, r := range selectRepos(manifest, args[1:]) {
+				if short, err := utils.RepoToPath(r.Repo); err == nil {
+					selected[short] = struct{}{}
+				}
+			}
+		}
+		for _, rel := range worktreeRepos(setdir) {
+			if _, ok := selected[rel]; len(selected) > 0 && !ok {
+				continue
+			}
+			path := filepath.Join(setdir, rel)
+			r := &config.Repo{Repo: rel}
+			if err := vcs.Git.RemoveWorktree(r, filepath.Join(root, rel), path, worktreeForce); err != nil {
+				logrus.WithError(err).WithField("repo", rel).Error("Unable to remove worktree")
+				continue
+			}
+			removeEmptyParents(filepath.Dir(path), config.WorktreesDir(root))
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(worktreeCmd)
+	worktreeCmd.AddCommand(worktreeAddCmd, worktreeListCmd, worktreeRemoveCmd)
+	worktreeAddCmd.Flags().StringVarP(&worktreeBranch, "branch", "b", "", "Branch to check out in every worktree (default is the set name)")
+	worktreeRemoveCmd.Flags().BoolVarP(&worktreeForce, "force", "f", false, "Remove worktrees even if they have local changes")
+}
@@ -66,3 +66,14 @@ func CreateJigRoot(path string) error {
 	}
 	return nil
 }
+
+// WorktreesDir returns the directory holding the worktree sets of a Jig root
+func WorktreesDir(root string) string {
+	return filepath.Join(root, JigDirName, "worktrees")
+}
+
+// WorktreeRoot returns the directory of a named worktree set, which is laid
+// out like the Jig root itself
+func WorktreeRoot(root, name string) string {
+	return filepath.Join(WorktreesDir(root), name)
+}
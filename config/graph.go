@@ -0,0 +1,156 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iancmcc/jig/utils"
+)
+
+// CycleError is returned for manifests whose repositories depend on each
+// other in a loop
+type CycleError struct {
+	// Cycle lists the repositories in the loop, starting and ending with the
+	// same one
+	Cycle []string
+}
+
+func (e *CycleError) Error() string {
+	return "Dependency cycle: " + strings.Join(e.Cycle, " -> ")
+}
+
+// Graph is the dependency graph of the repositories in a manifest. Nodes are
+// named by the path each repository is checked out to.
+type Graph struct {
+	// Nodes lists every repository, in manifest order
+	Nodes      []string
+	repos      map[string]*Repo
+	deps       map[string][]string
+	dependents map[string][]string
+}
+
+// Graph builds the dependency graph of the manifest, failing if a repository
+// depends on one that isn't in the manifest or the dependencies form a cycle
+func (m *Manifest) Graph() (*Graph, error) {
+	g := &Graph{
+		repos:      map[string]*Repo{},
+		deps:       map[string][]string{},
+		dependents: map[string][]string{},
+	}
+	for _, r := range m.Repos {
+		name, err := utils.RepoToPath(r.Repo)
+		if err != nil {
+			return nil, err
+		}
+		g.Nodes = append(g.Nodes, name)
+		g.repos[name] = r
+	}
+	for _, name := range g.Nodes {
+		seen := map[string]struct{}{}
+		for _, dep := range g.repos[name].DependsOn {
+			d, err := utils.RepoToPath(dep)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := g.repos[d]; !ok {
+				return nil, fmt.Errorf("%s depends on %s, which isn't in the manifest", name, dep)
+			}
+			if _, ok := seen[d]; ok {
+				continue
+			}
+			seen[d] = struct{}{}
+			g.deps[name] = append(g.deps[name], d)
+			g.dependents[d] = append(g.dependents[d], name)
+		}
+	}
+	if cycle := g.findCycle(); cycle != nil {
+		return nil, &CycleError{cycle}
+	}
+	return g, nil
+}
+
+// findCycle returns a dependency cycle, if there is one
+func (g *Graph) findCycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	stack := []string{}
+	var visit func(string) []string
+	visit = func(name string) []string {
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, dep := range g.deps[name] {
+			switch state[dep] {
+			case visiting:
+				for i, n := range stack {
+					if n == dep {
+						return append(append([]string{}, stack[i:]...), dep)
+					}
+				}
+			case unvisited:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, name := range g.Nodes {
+		if state[name] == unvisited {
+			if cycle := visit(name); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// Repo returns the repository for a node
+func (g *Graph) Repo(name string) *Repo {
+	return g.repos[name]
+}
+
+// DependsOn returns the repositories a node depends on directly
+func (g *Graph) DependsOn(name string) []string {
+	return g.deps[name]
+}
+
+// Dependents returns the repositories that depend directly on a node
+func (g *Graph) Dependents(name string) []string {
+	return g.dependents[name]
+}
+
+// Levels groups the nodes so that every node's dependencies are in earlier
+// levels. Nodes in the same level don't depend on each other, and keep their
+// manifest order.
+func (g *Graph) Levels() [][]string {
+	level := map[string]int{}
+	var depth func(string) int
+	depth = func(name string) int {
+		if l, ok := level[name]; ok {
+			return l
+		}
+		l := 0
+		for _, dep := range g.deps[name] {
+			if d := depth(dep) + 1; d > l {
+				l = d
+			}
+		}
+		level[name] = l
+		return l
+	}
+	levels := [][]string{}
+	for _, name := range g.Nodes {
+		l := depth(name)
+		for len(levels) <= l {
+			levels = append(levels, []string{})
+		}
+		levels[l] = append(levels[l], name)
+	}
+	return levels
+}
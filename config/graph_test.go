@@ -0,0 +1,66 @@
+package config_test
+
+import (
+	. "github.com/iancmcc/jig/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Graph", func() {
+
+	repo := func(uri string, deps ...string) *Repo {
+		return &Repo{Repo: uri, Ref: "master", DependsOn: deps}
+	}
+
+	It("should group repositories into levels after their dependencies", func() {
+		manifest := &Manifest{Repos: []*Repo{
+			repo("https://github.com/acme/deploy", "github.com/acme/api", "github.com/acme/web"),
+			repo("https://github.com/acme/web", "https://github.com/acme/lib"),
+			repo("https://github.com/acme/api", "github.com/acme/lib", "github.com/acme/lib"),
+			repo("https://github.com/acme/lib"),
+			repo("https://github.com/acme/docs"),
+		}}
+		g, err := manifest.Graph()
+		Expect(err).To(BeNil())
+		Expect(g.Levels()).To(Equal([][]string{
+			{"github.com/acme/lib", "github.com/acme/docs"},
+			{"github.com/acme/web", "github.com/acme/api"},
+			{"github.com/acme/deploy"},
+		}))
+		Expect(g.DependsOn("github.com/acme/api")).To(Equal([]string{"github.com/acme/lib"}))
+		Expect(g.Dependents("github.com/acme/lib")).To(ConsistOf("github.com/acme/web", "github.com/acme/api"))
+		Expect(g.Repo("github.com/acme/lib").Repo).To(Equal("https://github.com/acme/lib"))
+	})
+
+	It("should report dependency cycles", func() {
+		manifest := &Manifest{Repos: []*Repo{
+			repo("https://github.com/acme/a", "github.com/acme/b"),
+			repo("https://github.com/acme/b", "github.com/acme/c"),
+			repo("https://github.com/acme/c", "github.com/acme/a"),
+			repo("https://github.com/acme/d", "github.com/acme/a"),
+		}}
+		_, err := manifest.Graph()
+		Expect(err).To(BeAssignableToTypeOf(&CycleError{}))
+		Expect(err.(*CycleError).Cycle).To(Equal([]string{
+			"github.com/acme/a", "github.com/acme/b", "github.com/acme/c", "github.com/acme/a",
+		}))
+	})
+
+	It("should report a repository that depends on itself", func() {
+		manifest := &Manifest{Repos: []*Repo{
+			repo("https://github.com/acme/a", "github.com/acme/a"),
+		}}
+		_, err := manifest.Graph()
+		Expect(err).To(MatchError("Dependency cycle: github.com/acme/a -> github.com/acme/a"))
+	})
+
+	It("should reject dependencies missing from the manifest", func() {
+		manifest := &Manifest{Repos: []*Repo{
+			repo("https://github.com/acme/a", "github.com/acme/missing"),
+		}}
+		_, err := manifest.Graph()
+		Expect(err).To(HaveOccurred())
+	})
+
+})
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	HistoryName = "history"
+)
+
+// maxHistory caps the total of all visit counts. Past it, every count decays,
+// and repositories that haven't been visited in a while drop out.
+const maxHistory = 1000
+
+// Visit records how often and how recently a repository was jumped to
+type Visit struct {
+	// Count goes up by one for each visit, and decays as others pile up
+	Count float64
+	Last  time.Time
+}
+
+// Frecency combines how often and how recently a repository was visited
+func (v *Visit) Frecency(now time.Time) float64 {
+	age := now.Sub(v.Last)
+	switch {
+	case age < time.Hour:
+		return v.Count * 4
+	case age < 24*time.Hour:
+		return v.Count * 2
+	case age < 7*24*time.Hour:
+		return v.Count / 2
+	}
+	return v.Count / 4
+}
+
+// History records the repositories jumped to, keyed by the path they're
+// checked out to relative to the root
+type History struct {
+	Visits map[string]*Visit
+}
+
+func HistoryPath(dir string) (string, error) {
+	root, err := FindClosestJigRoot(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, JigDirName, HistoryName), nil
+}
+
+// DefaultHistory loads the history of the Jig root closest to dir
+func DefaultHistory(dir string) (*History, error) {
+	path, err := HistoryPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	h := &History{Visits: map[string]*Visit{}}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err := json.NewDecoder(file).Decode(h); err != nil {
+		return nil, err
+	}
+	if h.Visits == nil {
+		h.Visits = map[string]*Visit{}
+	}
+	return h, nil
+}
+
+// Record notes a visit to a repository
+func (h *History) Record(path string, now time.Time) {
+	v, ok := h.Visits[path]
+	if !ok {
+		v = &Visit{}
+		h.Visits[path] = v
+	}
+	v.Count++
+	v.Last = now
+	var total float64
+	for _, v := range h.Visits {
+		total += v.Count
+	}
+	if total <= maxHistory {
+		return
+	}
+	for p, v := range h.Visits {
+		v.Count *= 0.9
+		if v.Count < 1 {
+			delete(h.Visits, p)
+		}
+	}
+}
+
+// Forget drops a repository from the history, reporting whether it was there
+func (h *History) Forget(path string) bool {
+	_, ok := h.Visits[path]
+	delete(h.Visits, path)
+	return ok
+}
+
+// Frecency returns the frecency of every repository in the history
+func (h *History) Frecency(now time.Time) map[string]float64 {
+	f := make(map[string]float64, len(h.Visits))
+	for path, v := range h.Visits {
+		f[path] = v.Frecency(now)
+	}
+	return f
+}
+
+func (h *History) ToJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func (h *History) Save(dir string) error {
+	path, err := HistoryPath(dir)
+	if err != nil {
+		return err
+	}
+	return atomicWrite(path, h.ToJSON)
+}
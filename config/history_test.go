@@ -0,0 +1,80 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	. "github.com/iancmcc/jig/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("History", func() {
+
+	var (
+		tempdir string
+		now     = time.Date(2016, 9, 1, 12, 0, 0, 0, time.UTC)
+	)
+
+	BeforeEach(func() {
+		td, err := ioutil.TempDir("", "jig-")
+		if err != nil {
+			panic(err)
+		}
+		tempdir = td
+		os.Setenv("JIGROOT", tempdir)
+		Expect(CreateJigRoot(tempdir)).To(BeNil())
+	})
+
+	AfterEach(func() {
+		os.Setenv("JIGROOT", "")
+		if tempdir != "" {
+			os.RemoveAll(tempdir)
+		}
+		tempdir = ""
+	})
+
+	It("should count visits and save them", func() {
+		h, err := DefaultHistory(tempdir)
+		Expect(err).To(BeNil())
+		Expect(h.Visits).To(BeEmpty())
+		h.Record("github.com/iancmcc/jig", now.Add(-time.Hour))
+		h.Record("github.com/iancmcc/jig", now)
+		Expect(h.Save(tempdir)).To(BeNil())
+
+		loaded, err := DefaultHistory(tempdir)
+		Expect(err).To(BeNil())
+		Expect(loaded.Visits["github.com/iancmcc/jig"].Count).To(Equal(2.0))
+		Expect(loaded.Visits["github.com/iancmcc/jig"].Last.Equal(now)).To(BeTrue())
+	})
+
+	It("should favor recent visits over old ones", func() {
+		h := &History{Visits: map[string]*Visit{}}
+		h.Record("old", now.Add(-30*24*time.Hour))
+		h.Record("old", now.Add(-30*24*time.Hour))
+		h.Record("new", now.Add(-time.Minute))
+		f := h.Frecency(now)
+		Expect(f["new"]).To(BeNumerically(">", f["old"]))
+	})
+
+	It("should forget repositories", func() {
+		h := &History{Visits: map[string]*Visit{}}
+		h.Record("github.com/iancmcc/jig", now)
+		Expect(h.Forget("github.com/iancmcc/jig")).To(BeTrue())
+		Expect(h.Forget("github.com/iancmcc/jig")).To(BeFalse())
+		Expect(h.Visits).To(BeEmpty())
+	})
+
+	It("should age counts once they add up", func() {
+		h := &History{Visits: map[string]*Visit{}}
+		h.Record("rare", now)
+		for i := 0; i < 1000; i++ {
+			h.Record("often", now)
+		}
+		Expect(h.Visits).NotTo(HaveKey("rare"))
+		Expect(h.Visits["often"].Count).To(BeNumerically("<", 1000))
+	})
+
+})
@@ -0,0 +1,293 @@
+package config
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/iancmcc/jig/fs"
+)
+
+var (
+	IndexName = "index"
+)
+
+// IndexVersion identifies the format of the index. An index in another format
+// is thrown away and rebuilt.
+const IndexVersion = 1
+
+// Index records the repositories checked out below a Jig root, so they can be
+// listed without walking the whole tree
+type Index struct {
+	Version int
+	// Dirs holds the modification time of each directory walked to find
+	// repositories, keyed by path relative to the root. A directory whose
+	// time hasn't changed still has the same children.
+	Dirs map[string]int64
+	// Ignores holds the modification time of each ignore file found, keyed
+	// by the path of its directory relative to the root. Below one that has
+	// changed, every directory is read again.
+	Ignores map[string]int64 `json:",omitempty"`
+	// Repos are keyed by the path they're checked out to, relative to the root
+	Repos map[string]*IndexedRepo
+}
+
+// IndexedRepo is what the index knows about a repository
+type IndexedRepo struct {
+	// Repo is the URI of the repository, if known
+	Repo string `json:",omitempty"`
+	// Branch is the branch last seen checked out
+	Branch string `json:",omitempty"`
+	// Status is the status last seen, if it has been
+	Status *IndexedStatus `json:",omitempty"`
+}
+
+// IndexedStatus is the status of a repository at some point
+type IndexedStatus struct {
+	Staged, Unstaged, Untracked bool
+	Shallow, Partial, Sparse    bool
+	Ahead, Behind               int
+	Seen                        time.Time
+}
+
+// Unchanged reports whether the branch of the repository checked out in dir,
+// where it stands against its upstream on origin and its shape are as they
+// were when its status was seen, because nothing git keeps them in has been
+// modified since. Whether the working tree is dirty can't be told this way;
+// only git status knows that.
+func (e *IndexedRepo) Unchanged(dir string) bool {
+	if e.Status == nil || e.Branch == "" {
+		return false
+	}
+	gitdir := filepath.Join(dir, ".git")
+	// Worktrees keep what they know elsewhere
+	if info, err := os.Stat(gitdir); err != nil || !info.IsDir() {
+		return false
+	}
+	// Anything modified this close to when the status was seen might have
+	// been modified after
+	seen := e.Status.Seen.Add(-time.Second)
+	local, remote := "refs/heads/"+e.Branch, "refs/remotes/origin/"+e.Branch
+	for _, name := range []string{"HEAD", "config", "shallow", "packed-refs", local, path.Dir(local), remote, path.Dir(remote)} {
+		info, err := os.Stat(filepath.Join(gitdir, filepath.FromSlash(name)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil || !info.ModTime().Before(seen) {
+			return false
+		}
+	}
+	return true
+}
+
+func IndexPath(dir string) (string, error) {
+	root, err := FindClosestJigRoot(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, JigDirName, IndexName), nil
+}
+
+// NewIndex returns an empty index
+func NewIndex() *Index {
+	return &Index{
+		Version: IndexVersion,
+		Dirs:    map[string]int64{},
+		Ignores: map[string]int64{},
+		Repos:   map[string]*IndexedRepo{},
+	}
+}
+
+// DefaultIndex loads the index for the Jig root closest to dir. A missing or
+// outdated index loads empty.
+func DefaultIndex(dir string) (*Index, error) {
+	path, err := IndexPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return NewIndex(), nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	idx := NewIndex()
+	if err := json.NewDecoder(file).Decode(idx); err != nil || idx.Version != IndexVersion {
+		return NewIndex(), nil
+	}
+	return idx, nil
+}
+
+// Paths returns the paths of the indexed repositories, relative to the root,
+// in order
+func (idx *Index) Paths() []string {
+	paths := make([]string, 0, len(idx.Repos))
+	for path := range idx.Repos {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Set returns the entry for the repository checked out to path, relative to
+// the root, adding one if there isn't one
+func (idx *Index) Set(path string) *IndexedRepo {
+	path = filepath.Clean(path)
+	entry, ok := idx.Repos[path]
+	if !ok {
+		entry = &IndexedRepo{}
+		idx.Repos[path] = entry
+	}
+	return entry
+}
+
+// Refresh brings the index up to date with the repositories below root. Only
+// directories modified since they were last read are read again, unless
+// force is set. Directories are skipped as finders skip them, by
+// fs.DefaultSkip and ignore files. It reports whether anything changed.
+func (idx *Index) Refresh(root string, force bool) bool {
+	// Directories modified this close to now might be modified again without
+	// their time changing, so they're read again next time
+	racy := time.Now().Add(-time.Second).UnixNano()
+	children := map[string][]string{}
+	for dir := range idx.Dirs {
+		if dir != "." {
+			parent := filepath.Dir(dir)
+			children[parent] = append(children[parent], dir)
+		}
+	}
+	for path := range idx.Repos {
+		parent := filepath.Dir(path)
+		children[parent] = append(children[parent], path)
+	}
+	dirs := map[string]int64{}
+	ignores := map[string]int64{}
+	repos := map[string]*IndexedRepo{}
+	keep := func(path string) {
+		entry, ok := idx.Repos[path]
+		if !ok {
+			entry = &IndexedRepo{}
+		}
+		repos[path] = entry
+	}
+	// reread is set below an ignore file that has changed, as what it
+	// skipped, or stopped skipping, isn't in the index
+	var walk func(rel string, ignore fs.Ignore, reread bool)
+	walk = func(rel string, ignore fs.Ignore, reread bool) {
+		dir := filepath.Join(root, rel)
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			return
+		}
+		mtime := info.ModTime().UnixNano()
+		if mtime < racy {
+			dirs[rel] = mtime
+		} else {
+			dirs[rel] = 0
+		}
+		if info, err := os.Stat(filepath.Join(dir, fs.IgnoreFileName)); err == nil {
+			ignore = ignore.Enter(dir)
+			imtime := info.ModTime().UnixNano()
+			if last, ok := idx.Ignores[rel]; !ok || last != imtime {
+				reread = true
+			}
+			if imtime < racy {
+				ignores[rel] = imtime
+			} else {
+				ignores[rel] = 0
+			}
+		} else if _, ok := idx.Ignores[rel]; ok {
+			reread = true
+		}
+		if last, ok := idx.Dirs[rel]; ok && last == mtime && !force && !reread {
+			for _, child := range children[rel] {
+				_, repo := idx.Repos[child]
+				if ignore.Skipped(filepath.Join(root, child), repo) {
+					continue
+				}
+				if repo {
+					keep(child)
+				} else {
+					walk(child, ignore, false)
+				}
+			}
+			return
+		}
+		f, err := os.Open(dir)
+		if err != nil {
+			return
+		}
+		names, err := f.Readdirnames(-1)
+		f.Close()
+		if err != nil {
+			return
+		}
+		for _, name := range names {
+			// Worktree sets and anything else jig keeps for itself
+			if rel == "." && name == JigDirName {
+				continue
+			}
+			child := filepath.Join(rel, name)
+			info, err := os.Lstat(filepath.Join(root, child))
+			if err != nil || !info.IsDir() {
+				continue
+			}
+			_, err = os.Lstat(filepath.Join(root, child, ".git"))
+			repo := err == nil
+			if ignore.Skipped(filepath.Join(root, child), repo) {
+				continue
+			}
+			if repo {
+				keep(child)
+				continue
+			}
+			walk(child, ignore, reread)
+		}
+	}
+	walk(".", fs.NewIgnore(root, nil), false)
+	changed := len(dirs) != len(idx.Dirs) || len(repos) != len(idx.Repos) || len(ignores) != len(idx.Ignores)
+	for dir, mtime := range dirs {
+		if last, ok := idx.Dirs[dir]; !ok || last != mtime {
+			changed = true
+			break
+		}
+	}
+	for path := range repos {
+		if _, ok := idx.Repos[path]; !ok {
+			changed = true
+			break
+		}
+	}
+	for dir, mtime := range ignores {
+		if last, ok := idx.Ignores[dir]; !ok || last != mtime {
+			changed = true
+			break
+		}
+	}
+	idx.Dirs = dirs
+	idx.Ignores = ignores
+	idx.Repos = repos
+	return changed
+}
+
+func (idx *Index) ToJSON(w io.Writer) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func (idx *Index) Save(dir string) error {
+	path, err := IndexPath(dir)
+	if err != nil {
+		return err
+	}
+	return atomicWrite(path, idx.ToJSON)
+}
@@ -0,0 +1,183 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/fs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Index", func() {
+
+	var (
+		tempdir string
+		mkrepo  func(path string)
+		age     func(path string)
+	)
+
+	BeforeEach(func() {
+		td, err := ioutil.TempDir("", "jig-")
+		if err != nil {
+			panic(err)
+		}
+		tempdir = td
+		os.Setenv("JIGROOT", tempdir)
+		Expect(CreateJigRoot(tempdir)).To(BeNil())
+		mkrepo = func(path string) {
+			Expect(os.MkdirAll(filepath.Join(tempdir, path, ".git"), 0755)).To(BeNil())
+		}
+		// Make every directory old enough that the index trusts its time
+		age = func(path string) {
+			old := time.Unix(1500000000, 0)
+			filepath.Walk(filepath.Join(tempdir, path), func(p string, info os.FileInfo, err error) error {
+				if err == nil && info.IsDir() {
+					os.Chtimes(p, old, old)
+				}
+				return nil
+			})
+		}
+	})
+
+	AfterEach(func() {
+		os.Setenv("JIGROOT", "")
+		if tempdir != "" {
+			os.RemoveAll(tempdir)
+		}
+		tempdir = ""
+	})
+
+	It("should find the repositories below the root", func() {
+		mkrepo("github.com/iancmcc/jig")
+		mkrepo("github.com/iancmcc/other")
+		mkrepo("example.com/deep/down/repo")
+		mkrepo(".jig/worktrees/wip/github.com/iancmcc/jig")
+		mkrepo("github.com/iancmcc/jig/nested")
+
+		idx := NewIndex()
+		Expect(idx.Refresh(tempdir, false)).To(BeTrue())
+		Expect(idx.Paths()).To(Equal([]string{
+			"example.com/deep/down/repo",
+			"github.com/iancmcc/jig",
+			"github.com/iancmcc/other",
+		}))
+	})
+
+	It("should notice repositories added and removed since", func() {
+		mkrepo("github.com/iancmcc/jig")
+		mkrepo("github.com/iancmcc/other")
+		age(".")
+		idx := NewIndex()
+		idx.Refresh(tempdir, false)
+		idx.Set("github.com/iancmcc/jig").Branch = "develop"
+		Expect(idx.Refresh(tempdir, false)).To(BeFalse())
+
+		Expect(os.RemoveAll(filepath.Join(tempdir, "github.com/iancmcc/other"))).To(BeNil())
+		mkrepo("example.com/owner/repo")
+		Expect(idx.Refresh(tempdir, false)).To(BeTrue())
+		Expect(idx.Paths()).To(Equal([]string{
+			"example.com/owner/repo",
+			"github.com/iancmcc/jig",
+		}))
+		Expect(idx.Repos["github.com/iancmcc/jig"].Branch).To(Equal("develop"))
+	})
+
+	It("should only read directories that have changed unless forced", func() {
+		mkrepo("github.com/iancmcc/jig")
+		age(".")
+		idx := NewIndex()
+		idx.Refresh(tempdir, false)
+
+		// A repository turning up without its parent's time changing goes
+		// unnoticed until a forced rescan
+		mkrepo("github.com/iancmcc/other")
+		age(".")
+		Expect(idx.Refresh(tempdir, false)).To(BeFalse())
+		Expect(idx.Paths()).To(Equal([]string{"github.com/iancmcc/jig"}))
+		Expect(idx.Refresh(tempdir, true)).To(BeTrue())
+		Expect(idx.Paths()).To(Equal([]string{"github.com/iancmcc/jig", "github.com/iancmcc/other"}))
+	})
+
+	It("should notice when a status seen may be out of date", func() {
+		mkrepo("github.com/iancmcc/jig")
+		dir := filepath.Join(tempdir, "github.com/iancmcc/jig")
+		gitdir := filepath.Join(dir, ".git")
+		Expect(os.MkdirAll(filepath.Join(gitdir, "refs", "heads"), 0755)).To(BeNil())
+		for _, name := range []string{"HEAD", "config", "refs/heads/develop"} {
+			Expect(ioutil.WriteFile(filepath.Join(gitdir, name), []byte("x"), 0644)).To(BeNil())
+		}
+		age("github.com/iancmcc/jig")
+		old := time.Unix(1500000000, 0)
+		for _, name := range []string{"HEAD", "config", "refs/heads/develop"} {
+			Expect(os.Chtimes(filepath.Join(gitdir, name), old, old)).To(BeNil())
+		}
+
+		entry := &IndexedRepo{Branch: "develop"}
+		Expect(entry.Unchanged(dir)).To(BeFalse())
+		entry.Status = &IndexedStatus{Seen: time.Now()}
+		Expect(entry.Unchanged(dir)).To(BeTrue())
+
+		// A commit made by hand moves the branch
+		Expect(ioutil.WriteFile(filepath.Join(gitdir, "refs/heads/develop"), []byte("y"), 0644)).To(BeNil())
+		Expect(entry.Unchanged(dir)).To(BeFalse())
+		Expect(os.Chtimes(filepath.Join(gitdir, "refs/heads/develop"), old, old)).To(BeNil())
+		Expect(entry.Unchanged(dir)).To(BeTrue())
+
+		// So does fetching its upstream for the first time
+		Expect(os.MkdirAll(filepath.Join(gitdir, "refs/remotes/origin"), 0755)).To(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(gitdir, "refs/remotes/origin/develop"), []byte("z"), 0644)).To(BeNil())
+		Expect(entry.Unchanged(dir)).To(BeFalse())
+	})
+
+	It("should skip what finders skip, but not repositories", func() {
+		mkrepo("github.com/acme/vendor")
+		mkrepo("github.com/acme/app/node_modules/pkg")
+		mkrepo("example.com/old/repo")
+		mkrepo("example.com/new/repo")
+		ignore := filepath.Join(tempdir, "example.com", fs.IgnoreFileName)
+		Expect(ioutil.WriteFile(ignore, []byte("old\n"), 0644)).To(BeNil())
+		age(".")
+		old := time.Unix(1500000000, 0)
+		Expect(os.Chtimes(ignore, old, old)).To(BeNil())
+
+		idx := NewIndex()
+		idx.Refresh(tempdir, false)
+		Expect(idx.Paths()).To(Equal([]string{
+			"example.com/new/repo",
+			"github.com/acme/vendor",
+		}))
+
+		// Changing an ignore file doesn't change the time of its directory,
+		// but is still noticed
+		Expect(ioutil.WriteFile(ignore, []byte("new\n"), 0644)).To(BeNil())
+		Expect(os.Chtimes(ignore, old.Add(time.Hour), old.Add(time.Hour))).To(BeNil())
+		age(".")
+		Expect(idx.Refresh(tempdir, false)).To(BeTrue())
+		Expect(idx.Paths()).To(Equal([]string{
+			"example.com/old/repo",
+			"github.com/acme/vendor",
+		}))
+	})
+
+	It("should save and load", func() {
+		mkrepo("github.com/iancmcc/jig")
+		idx, err := DefaultIndex(tempdir)
+		Expect(err).To(BeNil())
+		Expect(idx.Repos).To(BeEmpty())
+		idx.Refresh(tempdir, false)
+		entry := idx.Set("github.com/iancmcc/jig")
+		entry.Repo = "https://github.com/iancmcc/jig"
+		entry.Status = &IndexedStatus{Unstaged: true, Seen: time.Unix(0, 0).UTC()}
+		Expect(idx.Save(tempdir)).To(BeNil())
+
+		loaded, err := DefaultIndex(tempdir)
+		Expect(err).To(BeNil())
+		Expect(loaded).To(Equal(idx))
+	})
+
+})
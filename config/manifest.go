@@ -25,6 +25,26 @@ type Manifest struct {
 type Repo struct {
 	Repo string
 	Ref  string
+	// Filter is a partial clone filter, such as blob:none or tree:0
+	Filter string `json:",omitempty"`
+	// Sparse limits the checkout to these directories
+	Sparse []string `json:",omitempty"`
+	// DependsOn names the repositories this one builds on, by URI or by
+	// the path they are checked out to
+	DependsOn []string `json:",omitempty"`
+	// Aliases are short names for the repository, which select it before
+	// anything else does
+	Aliases []string `json:",omitempty"`
+}
+
+// HasAlias reports whether a repository goes by an alias
+func (r *Repo) HasAlias(name string) bool {
+	for _, alias := range r.Aliases {
+		if alias == name {
+			return true
+		}
+	}
+	return false
 }
 
 // FromJSON creates a Manifest from a JSON reader
@@ -50,7 +70,12 @@ func (m *Manifest) Save(dir string) error {
 	if err != nil {
 		return err
 	}
+	return atomicWrite(path, m.ToJSON)
+}
 
+// atomicWrite writes to a temporary file next to path, then renames it into
+// place so readers never see a partial file
+func atomicWrite(path string, write func(w io.Writer) error) error {
 	tmp := path + "~"
 	defer os.Remove(tmp)
 
@@ -60,7 +85,7 @@ func (m *Manifest) Save(dir string) error {
 	}
 	defer tmpfile.Close()
 
-	if err := m.ToJSON(tmpfile); err != nil {
+	if err := write(tmpfile); err != nil {
 		return err
 	}
 
@@ -108,6 +133,10 @@ func (m *Manifest) Add(repo *Repo) error {
 			continue
 		}
 		if sname == shortname {
+			// Aliases are set with jig alias, not in manifests
+			if len(repo.Aliases) == 0 {
+				repo.Aliases = r.Aliases
+			}
 			m.Repos[i] = repo
 			found = true
 			break
@@ -118,3 +147,29 @@ func (m *Manifest) Add(repo *Repo) error {
 	}
 	return nil
 }
+
+// Alias returns the repository that goes by an alias, or nil if none does
+func (m *Manifest) Alias(name string) *Repo {
+	for _, r := range m.Repos {
+		if r.HasAlias(name) {
+			return r
+		}
+	}
+	return nil
+}
+
+// KeepAliases gives repositories without aliases those they had in an older
+// manifest
+func (m *Manifest) KeepAliases(old *Manifest) {
+	aliases := map[string][]string{}
+	for _, r := range old.Repos {
+		if short, err := utils.RepoToPath(r.Repo); err == nil && len(r.Aliases) > 0 {
+			aliases[short] = r.Aliases
+		}
+	}
+	for _, r := range m.Repos {
+		if short, err := utils.RepoToPath(r.Repo); err == nil && len(r.Aliases) == 0 {
+			r.Aliases = aliases[short]
+		}
+	}
+}
@@ -29,3 +29,35 @@ var _ = Describe("Manifest from JSON", func() {
 		Expect(results.Repos).To(HaveLen(2))
 	})
 })
+
+var _ = Describe("Manifest aliases", func() {
+
+	var manifest *Manifest
+
+	BeforeEach(func() {
+		var err error
+		manifest, err = FromJSON(strings.NewReader(json))
+		Expect(err).To(BeNil())
+		manifest.Repos[1].Aliases = []string{"zen"}
+	})
+
+	It("should find a repository by alias", func() {
+		Expect(manifest.Alias("zen")).To(Equal(manifest.Repos[1]))
+		Expect(manifest.Alias("jig")).To(BeNil())
+	})
+
+	It("should keep aliases when a repository is added again", func() {
+		Expect(manifest.Add(&Repo{Repo: "github.com/zenoss/zenoss", Ref: "develop"})).To(BeNil())
+		Expect(manifest.Repos[1].Ref).To(Equal("develop"))
+		Expect(manifest.Repos[1].Aliases).To(Equal([]string{"zen"}))
+	})
+
+	It("should keep aliases from an older manifest", func() {
+		restored, err := FromJSON(strings.NewReader(json))
+		Expect(err).To(BeNil())
+		restored.KeepAliases(manifest)
+		Expect(restored.Repos[0].Aliases).To(BeEmpty())
+		Expect(restored.Repos[1].Aliases).To(Equal([]string{"zen"}))
+	})
+
+})
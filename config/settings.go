@@ -0,0 +1,133 @@
+package config
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	SettingsName = "config.json"
+)
+
+// Settings holds the configuration for a Jig root
+type Settings struct {
+	Cache   CacheSettings
+	Clone   CloneSettings
+	Rewrite RewriteSettings
+}
+
+// CloneSettings are defaults for repositories in the manifest that don't
+// specify their own Filter or Sparse
+type CloneSettings struct {
+	Filter string   `json:",omitempty"`
+	Sparse []string `json:",omitempty"`
+}
+
+// CacheSettings configures the shared object cache used to speed up clones
+type CacheSettings struct {
+	Enabled bool
+	// Dir is the location of the cache. Relative paths are relative to the
+	// Jig root; empty means the user's cache directory.
+	Dir string `json:",omitempty"`
+}
+
+// RewriteSettings change the URLs repositories are cloned and fetched from.
+// Repositories are still checked out where their manifest URL says, so
+// rewriting never moves a checkout.
+type RewriteSettings struct {
+	// Prefixes maps URL prefixes used in the manifest to the prefixes to
+	// use instead. The longest matching prefix wins.
+	Prefixes map[string]string `json:",omitempty"`
+	// PreferSSH lists hosts whose https and git URLs should be cloned over
+	// ssh instead
+	PreferSSH []string `json:",omitempty"`
+}
+
+// Apply returns the URL to use for a repository URI from the manifest.
+// Prefixes are rewritten first, then the result is switched to ssh if its
+// host prefers it.
+func (r RewriteSettings) Apply(uri string) string {
+	var from string
+	for prefix := range r.Prefixes {
+		if strings.HasPrefix(uri, prefix) && len(prefix) > len(from) {
+			from = prefix
+		}
+	}
+	if from != "" {
+		uri = r.Prefixes[from] + strings.TrimPrefix(uri, from)
+	}
+	for _, scheme := range []string{"https://", "http://", "git://"} {
+		if !strings.HasPrefix(uri, scheme) {
+			continue
+		}
+		rest := strings.TrimPrefix(uri, scheme)
+		i := strings.Index(rest, "/")
+		if i < 0 {
+			break
+		}
+		host := rest[:i]
+		// Credentials for https mean nothing to ssh
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+		for _, h := range r.PreferSSH {
+			if strings.EqualFold(h, host) {
+				return "git@" + host + ":" + rest[i+1:]
+			}
+		}
+		break
+	}
+	return uri
+}
+
+// LocalCacheDir is the cache location used for a cache private to a Jig root
+var LocalCacheDir = filepath.Join(JigDirName, "cache")
+
+func SettingsPath(dir string) (string, error) {
+	root, err := FindClosestJigRoot(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, JigDirName, SettingsName), nil
+}
+
+// DefaultSettings loads the settings for the Jig root closest to dir. A root
+// without a settings file gets the zero value.
+func DefaultSettings(dir string) (*Settings, error) {
+	path, err := SettingsPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	var s Settings
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err := json.NewDecoder(file).Decode(&s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *Settings) ToJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func (s *Settings) Save(dir string) error {
+	path, err := SettingsPath(dir)
+	if err != nil {
+		return err
+	}
+	return atomicWrite(path, s.ToJSON)
+}
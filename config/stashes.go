@@ -0,0 +1,103 @@
+package config
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	StashesName = "stashes"
+)
+
+// Stash is a set of git stashes, one per repository, saved together under a
+// name
+type Stash struct {
+	Name    string
+	Created time.Time
+	Repos   []*StashedRepo
+}
+
+// StashedRepo is the git stash holding the changes to a single repository
+type StashedRepo struct {
+	// Repo is the path the repository is checked out to
+	Repo string
+	// Commit identifies the git stash, which keeps working however many
+	// stashes are pushed on top of it
+	Commit string
+	// Branch is the branch the changes were made on
+	Branch string
+}
+
+// Stashes are the named stashes saved in a Jig root
+type Stashes struct {
+	Stashes []*Stash
+}
+
+func StashesPath(dir string) (string, error) {
+	root, err := FindClosestJigRoot(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, JigDirName, StashesName), nil
+}
+
+// DefaultStashes loads the named stashes for the Jig root closest to dir
+func DefaultStashes(dir string) (*Stashes, error) {
+	path, err := StashesPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &Stashes{Stashes: []*Stash{}}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err := json.NewDecoder(file).Decode(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get returns the stash with a name, or nil if there isn't one
+func (s *Stashes) Get(name string) *Stash {
+	for _, stash := range s.Stashes {
+		if stash.Name == name {
+			return stash
+		}
+	}
+	return nil
+}
+
+// Remove forgets the stash with a name
+func (s *Stashes) Remove(name string) {
+	kept := s.Stashes[:0]
+	for _, stash := range s.Stashes {
+		if stash.Name != name {
+			kept = append(kept, stash)
+		}
+	}
+	s.Stashes = kept
+}
+
+func (s *Stashes) ToJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func (s *Stashes) Save(dir string) error {
+	path, err := StashesPath(dir)
+	if err != nil {
+		return err
+	}
+	return atomicWrite(path, s.ToJSON)
+}
@@ -0,0 +1,67 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	. "github.com/iancmcc/jig/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stashes", func() {
+
+	var tempdir string
+
+	BeforeEach(func() {
+		td, err := ioutil.TempDir("", "jig-")
+		if err != nil {
+			panic(err)
+		}
+		tempdir = td
+		os.Setenv("JIGROOT", tempdir)
+		Expect(CreateJigRoot(tempdir)).To(BeNil())
+	})
+
+	AfterEach(func() {
+		os.Setenv("JIGROOT", "")
+		if tempdir != "" {
+			os.RemoveAll(tempdir)
+		}
+		tempdir = ""
+	})
+
+	It("should start out empty", func() {
+		stashes, err := DefaultStashes(tempdir)
+		Expect(err).To(BeNil())
+		Expect(stashes.Stashes).To(BeEmpty())
+		Expect(stashes.Get("wip")).To(BeNil())
+	})
+
+	It("should save, find and remove stashes by name", func() {
+		stashes, err := DefaultStashes(tempdir)
+		Expect(err).To(BeNil())
+		for _, name := range []string{"wip", "spike"} {
+			stashes.Stashes = append(stashes.Stashes, &Stash{
+				Name:    name,
+				Created: time.Unix(0, 0).UTC(),
+				Repos: []*StashedRepo{
+					{Repo: "github.com/iancmcc/jig", Commit: "abc123", Branch: "develop"},
+				},
+			})
+		}
+		Expect(stashes.Save(tempdir)).To(BeNil())
+
+		loaded, err := DefaultStashes(tempdir)
+		Expect(err).To(BeNil())
+		Expect(loaded).To(Equal(stashes))
+		Expect(loaded.Get("spike").Repos[0].Commit).To(Equal("abc123"))
+
+		loaded.Remove("wip")
+		Expect(loaded.Get("wip")).To(BeNil())
+		Expect(loaded.Stashes).To(HaveLen(1))
+	})
+
+})
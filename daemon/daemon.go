@@ -0,0 +1,444 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/fs"
+	"github.com/iancmcc/jig/utils"
+	"github.com/iancmcc/jig/vcs"
+)
+
+const (
+	// SocketName is the name of the daemon's socket in the .jig directory
+	SocketName = "daemon.sock"
+	// ProtocolVersion identifies the format of requests and responses
+	ProtocolVersion = 1
+
+	// Queries the daemon answers
+	QueryStatus = "status"
+	QueryLs     = "ls"
+	QueryRoot   = "root"
+	QueryStop   = "stop"
+
+	// dialTimeout is how long a client waits for the daemon, so a daemon
+	// that's stuck costs little more than one that isn't running
+	dialTimeout = 200 * time.Millisecond
+	// reloadInterval is how often the daemon looks for a changed manifest
+	reloadInterval = 10 * time.Second
+	// changeDebounce is how long a repository has to be quiet after changing
+	// before its status is recomputed
+	changeDebounce = 500 * time.Millisecond
+)
+
+var (
+	// ErrNotRunning is returned when no daemon is serving a Jig root
+	ErrNotRunning = errors.New("no daemon running")
+	// ErrRunning is returned when starting a daemon for a Jig root that
+	// already has one
+	ErrRunning = errors.New("a daemon is already running")
+)
+
+// Request is a query sent to the daemon, as a line of JSON
+type Request struct {
+	Version int
+	Query   string
+}
+
+// Response answers a query, as a line of JSON
+type Response struct {
+	Version int
+	Root    string `json:",omitempty"`
+	// Repos are the paths of the repositories below the root, relative to it
+	Repos []string `json:",omitempty"`
+	// Statuses are those of the repositories in the manifest
+	Statuses []*vcs.Status `json:",omitempty"`
+	Error    string        `json:",omitempty"`
+}
+
+// SocketPath returns where the daemon for a Jig root listens
+func SocketPath(root string) string {
+	return filepath.Join(config.JigRootDir(root), SocketName)
+}
+
+// Query asks the daemon for a Jig root a question. ErrNotRunning is returned
+// if there's no daemon to ask.
+func Query(root, query string) (*Response, error) {
+	conn, err := net.DialTimeout("unix", SocketPath(root), dialTimeout)
+	if err != nil {
+		return nil, ErrNotRunning
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(&Request{Version: ProtocolVersion, Query: query}); err != nil {
+		return nil, err
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
+
+// Options control how often and how hard the daemon works
+type Options struct {
+	// Interval is roughly how often each repository is fetched
+	Interval time.Duration
+	// Parallel limits how many git commands run at once
+	Parallel int
+	// Offline stops the daemon fetching at all
+	Offline bool
+}
+
+// Server keeps the status of the repositories in a Jig root up to date,
+// fetching them now and then, and answers queries about them
+type Server struct {
+	Root string
+	Options
+	// Seen, if set, is called with each status the server computes, one at a
+	// time
+	Seen func(repo *config.Repo, stat *vcs.Status)
+
+	mu       sync.Mutex
+	seenMu   sync.Mutex
+	repos    map[string]*config.Repo
+	pending  map[string]*config.Repo
+	statuses map[string]*vcs.Status
+	// fetching holds the repositories with a fetch loop running
+	fetching     map[string]bool
+	manifestTime time.Time
+	watcher      *fs.RepoWatcher
+	listener     net.Listener
+	sem          chan struct{}
+	done         chan struct{}
+	stopOnce     sync.Once
+}
+
+// NewServer creates a server for a Jig root
+func NewServer(root string, opts Options) *Server {
+	if opts.Interval <= 0 {
+		opts.Interval = 5 * time.Minute
+	}
+	if opts.Parallel <= 0 {
+		opts.Parallel = 4
+	}
+	return &Server{
+		Root:     root,
+		Options:  opts,
+		repos:    map[string]*config.Repo{},
+		pending:  map[string]*config.Repo{},
+		statuses: map[string]*vcs.Status{},
+		fetching: map[string]bool{},
+		sem:      make(chan struct{}, opts.Parallel),
+		done:     make(chan struct{}),
+	}
+}
+
+// Serve answers queries until the server is stopped
+func (s *Server) Serve() error {
+	sock := SocketPath(s.Root)
+	if _, err := Query(s.Root, QueryRoot); err == nil {
+		return ErrRunning
+	}
+	// Left behind by a daemon that didn't get to clean up
+	os.Remove(sock)
+	listener, err := net.Listen("unix", sock)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+	defer os.Remove(sock)
+	select {
+	case <-s.done:
+		// Stopped before it got going
+		listener.Close()
+	default:
+	}
+
+	watcher, err := fs.NewRepoWatcher(changeDebounce)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	s.watcher = watcher
+	defer watcher.Close()
+
+	s.reload()
+	go s.watch()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return nil
+			default:
+			}
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// Stop stops the server
+func (s *Server) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.listener != nil {
+			s.listener.Close()
+		}
+	})
+}
+
+// reload picks up repositories added to the manifest since it was last read,
+// once they've been cloned, and drops those removed from it
+func (s *Server) reload() {
+	if path, err := config.ManifestPath(s.Root); err == nil {
+		if info, err := os.Stat(path); err == nil && !info.ModTime().Equal(s.manifestTime) {
+			s.manifestTime = info.ModTime()
+			if manifest, err := config.DefaultManifest(s.Root); err != nil {
+				logrus.WithError(err).Warn("Unable to read manifest")
+			} else {
+				s.mu.Lock()
+				listed := map[string]bool{}
+				for _, repo := range manifest.Repos {
+					short, err := utils.RepoToPath(repo.Repo)
+					if err != nil {
+						continue
+					}
+					listed[short] = true
+					if _, ok := s.repos[short]; ok {
+						s.repos[short] = repo
+					} else {
+						s.pending[short] = repo
+					}
+				}
+				// Repositories dropped from the manifest stop being watched
+				// and fetched
+				dropped := []string{}
+				for short := range s.repos {
+					if !listed[short] {
+						delete(s.repos, short)
+						delete(s.statuses, short)
+						dropped = append(dropped, short)
+					}
+				}
+				for short := range s.pending {
+					if !listed[short] {
+						delete(s.pending, short)
+					}
+				}
+				s.mu.Unlock()
+				for _, short := range dropped {
+					s.watcher.Unwatch(filepath.Join(s.Root, short))
+				}
+			}
+		}
+	}
+	s.mu.Lock()
+	ready := []string{}
+	fetch := []string{}
+	for short, repo := range s.pending {
+		if _, err := os.Stat(filepath.Join(s.Root, short)); err == nil {
+			delete(s.pending, short)
+			s.repos[short] = repo
+			ready = append(ready, short)
+			// A repository dropped and added again may still have its
+			// loop, waiting for its next fetch
+			if !s.Offline && !s.fetching[short] {
+				s.fetching[short] = true
+				fetch = append(fetch, short)
+			}
+		}
+	}
+	s.mu.Unlock()
+	for _, short := range ready {
+		if err := s.watcher.Watch(filepath.Join(s.Root, short)); err != nil {
+			logrus.WithError(err).WithField("repo", short).Warn("Unable to watch all of repo; its status will be left to git")
+		}
+		go s.refresh(short)
+	}
+	for _, short := range fetch {
+		go s.fetchLoop(short)
+	}
+}
+
+// watch recomputes the status of repositories as they change, and reloads
+// the manifest now and then
+func (s *Server) watch() {
+	tick := time.NewTicker(reloadInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case dir, ok := <-s.watcher.Changes:
+			if !ok {
+				return
+			}
+			if short, err := filepath.Rel(s.Root, dir); err == nil {
+				go s.refresh(short)
+			}
+		case err := <-s.watcher.Errors:
+			logrus.WithError(err).Debug("Error watching repositories")
+		case <-tick.C:
+			s.reload()
+		}
+	}
+}
+
+// acquire waits for a turn to run git, returning false if the server stopped
+func (s *Server) acquire() bool {
+	select {
+	case s.sem <- struct{}{}:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+func (s *Server) release() {
+	<-s.sem
+}
+
+// refresh recomputes the status of a repository
+func (s *Server) refresh(short string) {
+	s.mu.Lock()
+	repo := s.repos[short]
+	s.mu.Unlock()
+	if repo == nil || !s.acquire() {
+		return
+	}
+	stat, err := vcs.Git.Status(repo, filepath.Join(s.Root, short))
+	s.release()
+	if err != nil {
+		logrus.WithError(err).WithField("repo", short).Debug("Unable to get status for repo")
+		return
+	}
+	s.mu.Lock()
+	s.statuses[short] = stat
+	s.mu.Unlock()
+	if s.Seen != nil {
+		s.seenMu.Lock()
+		s.Seen(repo, stat)
+		s.seenMu.Unlock()
+	}
+}
+
+// jitter spreads d by a tenth either way, so repositories added together
+// aren't fetched together forever after
+func jitter(d time.Duration) time.Duration {
+	spread := int64(d) / 5
+	if spread <= 0 {
+		return d
+	}
+	return d - time.Duration(spread/2) + time.Duration(rand.Int63n(spread))
+}
+
+// fetchLoop fetches a repository every interval or so, until it's dropped
+// from the manifest
+func (s *Server) fetchLoop(short string) {
+	// Spread the first fetches over the whole interval
+	wait := time.Duration(rand.Int63n(int64(s.Interval)))
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-time.After(wait):
+		}
+		wait = jitter(s.Interval)
+		s.mu.Lock()
+		repo := s.repos[short]
+		if repo == nil {
+			delete(s.fetching, short)
+		}
+		s.mu.Unlock()
+		if repo == nil || !s.acquire() {
+			return
+		}
+		log := logrus.WithField("repo", short)
+		log.Debug("Fetching")
+		fetchchan, err := vcs.Git.Fetch(repo, filepath.Join(s.Root, short))
+		if err == nil {
+			for p := range fetchchan {
+				if p.Err != nil {
+					err = p.Err
+				}
+			}
+		}
+		s.release()
+		if err != nil {
+			log.WithError(err).Warn("Unable to fetch repo")
+			continue
+		}
+		s.refresh(short)
+	}
+}
+
+// handle answers the query on a connection
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+	if req.Version != ProtocolVersion {
+		json.NewEncoder(conn).Encode(&Response{
+			Version: ProtocolVersion,
+			Error:   fmt.Sprintf("protocol version %d isn't supported; the daemon speaks version %d", req.Version, ProtocolVersion),
+		})
+		return
+	}
+	resp := &Response{Version: ProtocolVersion, Root: s.Root}
+	switch req.Query {
+	case QueryRoot:
+	case QueryLs:
+		// Statuses are saved to the index as they're seen, so it's read
+		// fresh every time
+		s.seenMu.Lock()
+		idx, err := config.DefaultIndex(s.Root)
+		if err == nil && idx.Refresh(s.Root, false) {
+			err = idx.Save(s.Root)
+		}
+		s.seenMu.Unlock()
+		if err != nil {
+			resp.Error = err.Error()
+			break
+		}
+		resp.Repos = idx.Paths()
+	case QueryStatus:
+		s.mu.Lock()
+		for short, stat := range s.statuses {
+			// Changes to a repository that isn't all watched can go unseen,
+			// so its status can't be trusted; leaving it out has it checked
+			// with git instead
+			if s.watcher.Complete(filepath.Join(s.Root, short)) {
+				resp.Statuses = append(resp.Statuses, stat)
+			}
+		}
+		s.mu.Unlock()
+		sort.Slice(resp.Statuses, func(i, j int) bool { return resp.Statuses[i].Repo < resp.Statuses[j].Repo })
+	case QueryStop:
+		defer s.Stop()
+	default:
+		resp.Error = "unknown query " + req.Query
+	}
+	json.NewEncoder(conn).Encode(resp)
+}
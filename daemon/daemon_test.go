@@ -0,0 +1,100 @@
+package daemon_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/iancmcc/jig/config"
+	. "github.com/iancmcc/jig/daemon"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Daemon", func() {
+
+	var (
+		root   string
+		server *Server
+		served chan error
+	)
+
+	BeforeEach(func() {
+		td, err := ioutil.TempDir("", "jig-")
+		Expect(err).To(BeNil())
+		root = td
+		Expect(config.CreateJigRoot(root)).To(BeNil())
+		for _, repo := range []string{"github.com/iancmcc/jig", "github.com/iancmcc/other"} {
+			Expect(os.MkdirAll(filepath.Join(root, repo, ".git"), 0755)).To(BeNil())
+		}
+		server = NewServer(root, Options{Offline: true})
+		served = make(chan error, 1)
+	})
+
+	AfterEach(func() {
+		server.Stop()
+		os.RemoveAll(root)
+	})
+
+	serve := func() {
+		s, ch := server, served
+		go func() { ch <- s.Serve() }()
+		Eventually(func() error {
+			_, err := Query(root, QueryRoot)
+			return err
+		}).Should(Succeed())
+	}
+
+	It("should report when it isn't running", func() {
+		_, err := Query(root, QueryRoot)
+		Expect(err).To(Equal(ErrNotRunning))
+	})
+
+	It("should answer queries over its socket", func() {
+		serve()
+		resp, err := Query(root, QueryRoot)
+		Expect(err).To(BeNil())
+		Expect(resp.Root).To(Equal(root))
+
+		resp, err = Query(root, QueryLs)
+		Expect(err).To(BeNil())
+		Expect(resp.Repos).To(Equal([]string{"github.com/iancmcc/jig", "github.com/iancmcc/other"}))
+
+		resp, err = Query(root, QueryStatus)
+		Expect(err).To(BeNil())
+		Expect(resp.Statuses).To(BeEmpty())
+
+		_, err = Query(root, "bogus")
+		Expect(err).To(MatchError("unknown query bogus"))
+	})
+
+	It("should refuse requests in another protocol version", func() {
+		serve()
+		conn, err := net.Dial("unix", SocketPath(root))
+		Expect(err).To(BeNil())
+		defer conn.Close()
+		Expect(json.NewEncoder(conn).Encode(&Request{Version: ProtocolVersion + 1, Query: QueryRoot})).To(BeNil())
+		var resp Response
+		Expect(json.NewDecoder(conn).Decode(&resp)).To(BeNil())
+		Expect(resp.Error).To(ContainSubstring("isn't supported"))
+		Expect(resp.Root).To(BeEmpty())
+	})
+
+	It("should refuse to start twice", func() {
+		serve()
+		Expect(NewServer(root, Options{Offline: true}).Serve()).To(Equal(ErrRunning))
+	})
+
+	It("should clean up when stopped", func() {
+		serve()
+		_, err := Query(root, QueryStop)
+		Expect(err).To(BeNil())
+		Eventually(served).Should(Receive(BeNil()))
+		_, err = os.Stat(SocketPath(root))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+})
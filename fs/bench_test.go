@@ -0,0 +1,102 @@
+package fs_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/iancmcc/jig/fs"
+)
+
+// syntheticTree builds a Jig root of hosts, owners and repositories, each
+// repository with some source and a node_modules full of packages, and returns
+// its path and how many repositories are in it
+func syntheticTree(b *testing.B) (string, int) {
+	root, err := ioutil.TempDir("", "jig-bench-")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var repos int
+	for h := 0; h < 3; h++ {
+		for o := 0; o < 10; o++ {
+			for r := 0; r < 10; r++ {
+				repo := filepath.Join(root, fmt.Sprintf("host%d.com/owner%d/repo%d", h, o, r))
+				dirs := []string{".git/objects", "src/pkg", "docs"}
+				for p := 0; p < 20; p++ {
+					dirs = append(dirs, fmt.Sprintf("node_modules/pkg%d/lib", p))
+				}
+				for _, dir := range dirs {
+					if err := os.MkdirAll(filepath.Join(repo, dir), 0755); err != nil {
+						b.Fatal(err)
+					}
+				}
+				for _, file := range []string{"README.md", "src/main.go", "src/pkg/pkg.go"} {
+					if err := ioutil.WriteFile(filepath.Join(repo, file), nil, 0644); err != nil {
+						b.Fatal(err)
+					}
+				}
+				repos++
+			}
+		}
+	}
+	return root, repos
+}
+
+func benchmarkFind(b *testing.B, finder *ParallelFinder, depth int) {
+	root, repos := syntheticTree(b)
+	defer os.RemoveAll(root)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var found int
+		for range finder.FindBelowWithChildrenNamed(root, ".git", depth) {
+			found++
+		}
+		if found != repos {
+			b.Fatalf("found %d repos, expected %d", found, repos)
+		}
+	}
+	b.StopTimer()
+}
+
+// Finding the repositories, as jig ls does
+func BenchmarkFindRepos(b *testing.B) {
+	benchmarkFind(b, DefaultFinder(), 1)
+}
+
+// Finding repositories nested anywhere, which walks every repository too
+func BenchmarkFindNestedRepos(b *testing.B) {
+	benchmarkFind(b, DefaultFinder(), 0)
+}
+
+// The same without the default skip list, going through node_modules
+func BenchmarkFindNestedReposNoSkip(b *testing.B) {
+	finder := DefaultFinder()
+	finder.Skip = []string{}
+	benchmarkFind(b, finder, 0)
+}
+
+// One worker, to compare against the pool
+func BenchmarkFindNestedReposOneWorker(b *testing.B) {
+	finder := DefaultFinder()
+	finder.Workers = 1
+	benchmarkFind(b, finder, 0)
+}
+
+// namesOnly hides ListEntries from a lister, so a finder has to try listing
+// every child to learn whether it's a directory
+type namesOnly struct {
+	Lister
+}
+
+func (l namesOnly) Identify(path string) (FileID, error) {
+	return l.Lister.(Identifier).Identify(path)
+}
+
+// Without knowing which children are directories
+func BenchmarkFindNestedReposNamesOnly(b *testing.B) {
+	finder := DefaultFinder()
+	finder.Lister = namesOnly{finder.Lister}
+	benchmarkFind(b, finder, 0)
+}
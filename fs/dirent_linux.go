@@ -0,0 +1,109 @@
+//go:build linux
+// +build linux
+
+package fs
+
+import (
+	"bytes"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// direntBufferSize is how much of a directory is read at once
+const direntBufferSize = 16 * 1024
+
+// DirentLister lists directories with getdents, which says what type each
+// child is, so finding repositories needn't open every file to learn it isn't
+// a directory
+type DirentLister struct{}
+
+// ListChildren satisfies the Lister interface
+func (l *DirentLister) ListChildren(path string) ([]string, error) {
+	entries, err := l.ListEntries(path)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name
+	}
+	return names, nil
+}
+
+// ListEntries satisfies the EntryLister interface. Symlinks, and children of
+// file systems that don't say what type they are, may be directories.
+func (l *DirentLister) ListEntries(path string) ([]Entry, error) {
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err == unix.ENOTDIR {
+		return []Entry{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer unix.Close(fd)
+	buf := make([]byte, direntBufferSize)
+	entries := []Entry{}
+	for {
+		n, err := unix.ReadDirent(fd, buf)
+		if err == unix.EINTR {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if n <= 0 {
+			return entries, nil
+		}
+		entries = parseDirents(buf[:n], entries)
+	}
+}
+
+// Identify satisfies the Identifier interface
+func (l *DirentLister) Identify(path string) (FileID, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		return FileID{}, err
+	}
+	return FileID{uint64(stat.Dev), uint64(stat.Ino)}, nil
+}
+
+// parseDirents appends the entries in buf, as filled by getdents, to entries
+func parseDirents(buf []byte, entries []Entry) []Entry {
+	var d unix.Dirent
+	var (
+		inoOff    = unsafe.Offsetof(d.Ino)
+		reclenOff = unsafe.Offsetof(d.Reclen)
+		typeOff   = unsafe.Offsetof(d.Type)
+		nameOff   = unsafe.Offsetof(d.Name)
+	)
+	for uintptr(len(buf)) > nameOff {
+		// Only read the fields, as the last record is shorter than a Dirent
+		reclen := *(*uint16)(unsafe.Pointer(&buf[reclenOff]))
+		if reclen == 0 || int(reclen) > len(buf) {
+			break
+		}
+		rec := buf[:reclen]
+		buf = buf[reclen:]
+		// Deleted while being read
+		if *(*uint64)(unsafe.Pointer(&rec[inoOff])) == 0 {
+			continue
+		}
+		name := rec[nameOff:]
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+		if string(name) == "." || string(name) == ".." {
+			continue
+		}
+		switch rec[typeOff] {
+		case unix.DT_DIR, unix.DT_LNK, unix.DT_UNKNOWN:
+			entries = append(entries, Entry{string(name), true})
+		default:
+			entries = append(entries, Entry{string(name), false})
+		}
+	}
+	return entries
+}
+
+func defaultLister() Lister {
+	return &DirentLister{}
+}
@@ -0,0 +1,78 @@
+package fs_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/iancmcc/jig/fs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DirentLister", func() {
+
+	var (
+		tempdir string
+		lister  *DirentLister
+	)
+
+	BeforeEach(func() {
+		td, err := ioutil.TempDir("", "jig-")
+		Ω(err).ShouldNot(HaveOccurred())
+		tempdir = td
+		lister = &DirentLister{}
+		Ω(os.MkdirAll(filepath.Join(tempdir, "dir", ".git"), 0755)).Should(Succeed())
+		Ω(ioutil.WriteFile(filepath.Join(tempdir, "file"), nil, 0644)).Should(Succeed())
+		Ω(os.Symlink("dir", filepath.Join(tempdir, "link"))).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempdir)
+	})
+
+	It("lists children with whether they may be directories", func() {
+		entries, err := lister.ListEntries(tempdir)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(entries).Should(ConsistOf(
+			Entry{Name: "dir", Dir: true},
+			Entry{Name: "file", Dir: false},
+			Entry{Name: "link", Dir: true},
+		))
+		names, err := lister.ListChildren(tempdir)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(names).Should(ConsistOf("dir", "file", "link"))
+	})
+
+	It("lists nothing below a file", func() {
+		entries, err := lister.ListEntries(filepath.Join(tempdir, "file"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(entries).Should(BeEmpty())
+		_, err = lister.ListEntries(filepath.Join(tempdir, "missing"))
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("lists directories too big to read at once", func() {
+		big := filepath.Join(tempdir, "big")
+		Ω(os.Mkdir(big, 0755)).Should(Succeed())
+		for i := 0; i < 2000; i++ {
+			Ω(ioutil.WriteFile(filepath.Join(big, fmt.Sprintf("file%d", i)), nil, 0644)).Should(Succeed())
+		}
+		entries, err := lister.ListEntries(big)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(entries).Should(HaveLen(2000))
+	})
+
+	It("finds repositories, through symlinks only once", func() {
+		var names []string
+		for s := range (&ParallelFinder{Lister: lister}).FindBelowWithChildrenNamed(tempdir, ".git", 0) {
+			rel, _ := filepath.Rel(tempdir, s)
+			names = append(names, rel)
+		}
+		Ω(names).Should(HaveLen(1))
+		Ω([]string{"dir", "link"}).Should(ContainElement(names[0]))
+	})
+
+})
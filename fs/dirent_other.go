@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package fs
+
+func defaultLister() Lister {
+	return &basicLister{}
+}
@@ -0,0 +1,43 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Subdirs returns the directories below root, relative to it, down to depth
+// levels. Hidden directories, like .git, and repositories nested inside are
+// left out.
+func Subdirs(root string, depth int) []string {
+	dirs := []string{}
+	var walk func(rel string, level int)
+	walk = func(rel string, level int) {
+		f, err := os.Open(filepath.Join(root, rel))
+		if err != nil {
+			return
+		}
+		infos, err := f.Readdir(-1)
+		f.Close()
+		if err != nil {
+			return
+		}
+		for _, info := range infos {
+			if !info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+				continue
+			}
+			child := filepath.Join(rel, info.Name())
+			if _, err := os.Lstat(filepath.Join(root, child, ".git")); err == nil {
+				continue
+			}
+			dirs = append(dirs, child)
+			if level < depth {
+				walk(child, level+1)
+			}
+		}
+	}
+	walk("", 1)
+	sort.Strings(dirs)
+	return dirs
+}
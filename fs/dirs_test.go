@@ -0,0 +1,40 @@
+package fs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/iancmcc/jig/fs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Subdirs", func() {
+
+	var tempdir string
+
+	BeforeEach(func() {
+		td, err := ioutil.TempDir("", "jig-")
+		Expect(err).To(BeNil())
+		tempdir = td
+		for _, dir := range []string{".git/objects", "cmd/jig", "docs/api/v1", ".hidden", "nested/.git"} {
+			Expect(os.MkdirAll(filepath.Join(tempdir, dir), 0755)).To(BeNil())
+		}
+		Expect(ioutil.WriteFile(filepath.Join(tempdir, "README.md"), nil, 0644)).To(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempdir)
+	})
+
+	It("lists directories, leaving out hidden ones and nested repositories", func() {
+		Expect(Subdirs(tempdir, 5)).To(Equal([]string{"cmd", "cmd/jig", "docs", "docs/api", "docs/api/v1"}))
+	})
+
+	It("stops at a depth", func() {
+		Expect(Subdirs(tempdir, 2)).To(Equal([]string{"cmd", "cmd/jig", "docs", "docs/api"}))
+	})
+
+})
@@ -1,12 +1,17 @@
 package fs
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"sync"
 )
 
-var empty []string
+var (
+	empty           []string
+	errUnidentified = errors.New("unable to identify file")
+)
 
 // Finder can find files or directories that match strings
 type Finder interface {
@@ -14,6 +19,11 @@ type Finder interface {
 	FindBelowNamed(path, match string, depth int) <-chan string
 	// FindBelowNamed finds all files below path that have children named match
 	FindBelowWithChildrenNamed(path, match string, depth int) <-chan string
+	// FindBelowNamedContext is FindBelowNamed, stopping when ctx is done
+	FindBelowNamedContext(ctx context.Context, path, match string, depth int) <-chan string
+	// FindBelowWithChildrenNamedContext is FindBelowWithChildrenNamed,
+	// stopping when ctx is done
+	FindBelowWithChildrenNamedContext(ctx context.Context, path, match string, depth int) <-chan string
 }
 
 // Lister lists children below a given path
@@ -46,87 +56,282 @@ func (l *basicLister) ListChildren(path string) ([]string, error) {
 	return names, nil
 }
 
-// ParallelFinder finds files in parallel
+// Entry is a child of a directory
+type Entry struct {
+	Name string
+	// Dir is false only for children known not to be directories
+	Dir bool
+}
+
+// EntryLister is a Lister that knows which children are directories without
+// looking at each of them, so finders needn't try to list the rest
+type EntryLister interface {
+	ListEntries(path string) ([]Entry, error)
+}
+
+// DefaultWorkers is how many directories a finder lists at once unless told
+// otherwise
+const DefaultWorkers = 16
+
+// FileID identifies a directory however it's reached, so a walk that comes
+// back to one through a symlink knows it's been there
+type FileID struct {
+	Dev, Ino uint64
+}
+
+// Identifier is a Lister that can identify the directories it lists
+type Identifier interface {
+	Identify(path string) (FileID, error)
+}
+
+// ParallelFinder finds files in parallel, listing at most Workers
+// directories at once. A find keeps its own state, so one finder can be
+// used for several at the same time.
 type ParallelFinder struct {
 	Lister Lister
-	wg     sync.WaitGroup
+	// Workers limits how many directories are listed at once. Zero means
+	// DefaultWorkers.
+	Workers int
+	// Skip are globs naming directories not to go into. Nil means
+	// DefaultSkip; an empty list skips nothing.
+	Skip []string
+}
+
+// item is a directory waiting to be walked
+type item struct {
+	dir string
+	// level counts the matches found on the way to dir
+	level  int
+	ignore Ignore
+	// skippable is set for directories in the skip list, which are only
+	// walked if they turn out to be what's being found
+	skippable bool
+}
+
+// walk is the state of one find
+type walk struct {
+	finder *ParallelFinder
+	ctx    context.Context
+	mu     sync.Mutex
+	cond   *sync.Cond
+	// queue holds directories waiting for a worker; pending counts those and
+	// the ones being walked
+	queue   []item
+	pending int
+	seen    map[FileID]bool
+}
+
+// start walks path with a pool of workers, calling visit for each directory,
+// and closes out once every directory has been visited or ctx is done
+func (f *ParallelFinder) start(ctx context.Context, path string, out chan string, visit func(w *walk, it item)) {
+	w := &walk{finder: f, ctx: ctx, seen: map[FileID]bool{}}
+	w.cond = sync.NewCond(&w.mu)
+	w.push(item{dir: path, level: 1, ignore: NewIgnore(path, f.Skip)})
+	workers := f.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				it, ok := w.next()
+				if !ok {
+					return
+				}
+				visit(w, it)
+				w.finish()
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		// Wake the workers waiting for directories, so they see they're done
+		select {
+		case <-ctx.Done():
+			w.mu.Lock()
+			w.cond.Broadcast()
+			w.mu.Unlock()
+		case <-done:
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(done)
+		close(out)
+	}()
+}
+
+// push queues a directory to be walked
+func (w *walk) push(it item) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.queue = append(w.queue, it)
+	w.pending++
+	w.cond.Signal()
+}
+
+// next waits for a directory to walk, returning false once there are none
+// left and none being walked that could turn up more, or the find is
+// cancelled
+func (w *walk) next() (item, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for len(w.queue) == 0 && w.pending > 0 && w.ctx.Err() == nil {
+		w.cond.Wait()
+	}
+	if len(w.queue) == 0 || w.ctx.Err() != nil {
+		return item{}, false
+	}
+	// Last in, first out, so the queue stays about as long as the tree is
+	// deep rather than wide
+	it := w.queue[len(w.queue)-1]
+	w.queue = w.queue[:len(w.queue)-1]
+	return it, true
+}
+
+// finish marks a directory walked
+func (w *walk) finish() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending--
+	if w.pending == 0 {
+		w.cond.Broadcast()
+	}
+}
+
+// send reports a path found, returning false if the find was cancelled
+// before anyone took it
+func (w *walk) send(out chan<- string, path string) bool {
+	select {
+	case out <- path:
+		return true
+	case <-w.ctx.Done():
+		return false
+	}
+}
+
+// list lists a directory, returning nothing if it's been walked before. What's
+// ignored includes what its ignore file says, if it has one.
+func (w *walk) list(it item) ([]Entry, Ignore) {
+	if identifier, ok := w.finder.Lister.(Identifier); ok {
+		if id, err := identifier.Identify(it.dir); err == nil {
+			w.mu.Lock()
+			seen := w.seen[id]
+			w.seen[id] = true
+			w.mu.Unlock()
+			if seen {
+				return nil, Ignore{}
+			}
+		}
+	}
+	var entries []Entry
+	if el, ok := w.finder.Lister.(EntryLister); ok {
+		var err error
+		if entries, err = el.ListEntries(it.dir); err != nil {
+			return nil, Ignore{}
+		}
+	} else {
+		names, err := w.finder.Lister.ListChildren(it.dir)
+		if err != nil {
+			return nil, Ignore{}
+		}
+		entries = make([]Entry, len(names))
+		for i, name := range names {
+			entries[i] = Entry{name, true}
+		}
+	}
+	ignore := it.ignore
+	if containsEntry(IgnoreFileName, entries) {
+		ignore = ignore.Enter(it.dir)
+	}
+	return entries, ignore
 }
 
 // FindBelowNamed satisfies the Finder interface
 func (f *ParallelFinder) FindBelowNamed(path, match string, depth int) <-chan string {
+	return f.FindBelowNamedContext(context.Background(), path, match, depth)
+}
+
+// FindBelowWithChildrenNamed satisfies the Finder interface
+func (f *ParallelFinder) FindBelowWithChildrenNamed(path, match string, depth int) <-chan string {
+	return f.FindBelowWithChildrenNamedContext(context.Background(), path, match, depth)
+}
+
+// FindBelowNamedContext satisfies the Finder interface. Cancelling ctx stops
+// the walk and closes the channel, so those that stop reading early, having
+// found enough, should cancel it.
+func (f *ParallelFinder) FindBelowNamedContext(ctx context.Context, path, match string, depth int) <-chan string {
 	out := make(chan string)
-	var walk func(d string, wdepth int)
-	walk = func(d string, wdepth int) {
-		defer f.wg.Done()
-		names, err := f.Lister.ListChildren(d)
-		if err != nil {
+	f.start(ctx, path, out, func(w *walk, it item) {
+		entries, ignore := w.list(it)
+		if it.skippable && !containsEntry(match, entries) {
 			return
 		}
-		for _, name := range names {
-			p := filepath.Join(d, name)
-			if match == name {
-				out <- p
-				if depth > 0 && wdepth >= depth {
+		for _, entry := range entries {
+			p := filepath.Join(it.dir, entry.Name)
+			if ignore.Skipped(p, true) {
+				continue
+			}
+			level := it.level
+			if match == entry.Name {
+				if !w.send(out, p) {
+					return
+				}
+				if depth > 0 && level >= depth {
 					continue
 				}
+				level++
+			}
+			if entry.Dir {
+				w.push(item{p, level, ignore, match != entry.Name && ignore.Skipped(p, false)})
 			}
-			wdepth++
-			f.wg.Add(1)
-			go walk(p, wdepth)
 		}
-	}
-	f.wg.Add(1)
-	go walk(path, 1)
-	go func() {
-		defer close(out)
-		f.wg.Wait()
-	}()
+	})
 	return out
 }
 
-// FindBelowWithChildrenNamed satisfies the Finder interface
-func (f *ParallelFinder) FindBelowWithChildrenNamed(path, match string, depth int) <-chan string {
+// FindBelowWithChildrenNamedContext satisfies the Finder interface. As with
+// FindBelowNamedContext, cancelling ctx stops the walk.
+func (f *ParallelFinder) FindBelowWithChildrenNamedContext(ctx context.Context, path, match string, depth int) <-chan string {
 	out := make(chan string)
-	var walk func(d string, wdepth int)
-	walk = func(d string, wdepth int) {
-		defer f.wg.Done()
-		names, err := f.Lister.ListChildren(d)
-		if err != nil {
-			return
-		}
-		if contains(match, names) {
-			out <- d
-			if depth > 0 && wdepth >= depth {
+	f.start(ctx, path, out, func(w *walk, it item) {
+		entries, ignore := w.list(it)
+		level := it.level
+		if containsEntry(match, entries) {
+			if !w.send(out, it.dir) {
 				return
 			}
-			wdepth++
-
+			if depth > 0 && level >= depth {
+				return
+			}
+			level++
+		} else if it.skippable {
+			return
 		}
-		for _, name := range names {
-			f.wg.Add(1)
-			go walk(filepath.Join(d, name), wdepth)
+		for _, entry := range entries {
+			p := filepath.Join(it.dir, entry.Name)
+			if entry.Dir && !ignore.Skipped(p, true) {
+				w.push(item{p, level, ignore, ignore.Skipped(p, false)})
+			}
 		}
-	}
-	f.wg.Add(1)
-	go walk(path, 1)
-	go func() {
-		defer close(out)
-		f.wg.Wait()
-	}()
+	})
 	return out
 }
 
-func contains(filter string, candidates []string) bool {
-	for _, s := range candidates {
-		if s == filter {
+func containsEntry(name string, entries []Entry) bool {
+	for _, e := range entries {
+		if e.Name == name {
 			return true
 		}
 	}
 	return false
 }
 
-// DefaultFinder returns a simple parallel finder with the default os.Readdir
-// lister
+// DefaultFinder returns a simple parallel finder with the best lister for the
+// platform: a DirentLister on Linux, and one using os.Readdir elsewhere
 func DefaultFinder() *ParallelFinder {
-	return &ParallelFinder{Lister: &basicLister{}}
+	return &ParallelFinder{Lister: defaultLister()}
 }
@@ -1,6 +1,15 @@
 package fs_test
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
 	. "github.com/iancmcc/jig/fs"
 
 	. "github.com/onsi/ginkgo"
@@ -69,4 +78,195 @@ var _ = Describe("ParallelFinder", func() {
 		Ω(names).Should(ConsistOf("/a/c/d", "/a/b/d"))
 	})
 
+	It("skips dependency directories unless told otherwise", func() {
+		finder.Lister = &testLister{map[string][]string{
+			"/a":                  {"b", "node_modules"},
+			"/a/b":                {"d"},
+			"/a/node_modules":     {"pkg"},
+			"/a/node_modules/pkg": {"d"},
+		}}
+		var names []string
+		for s := range finder.FindBelowNamed("/a", "d", 0) {
+			names = append(names, s)
+		}
+		Ω(names).Should(ConsistOf("/a/b/d"))
+
+		finder.Skip = []string{}
+		names = nil
+		for s := range finder.FindBelowNamed("/a", "d", 0) {
+			names = append(names, s)
+		}
+		Ω(names).Should(ConsistOf("/a/b/d", "/a/node_modules/pkg/d"))
+	})
+
+	It("finds what's in a directory with a name it skips", func() {
+		finder.Lister = &testLister{map[string][]string{
+			"/a":                      {"acme"},
+			"/a/acme":                 {"vendor", "target"},
+			"/a/acme/vendor":          {".git", "node_modules"},
+			"/a/acme/vendor/.git":     {},
+			"/a/acme/target":          {"pkg"},
+			"/a/acme/target/pkg":      {".git"},
+			"/a/acme/target/pkg/.git": {},
+		}}
+		var names []string
+		for s := range finder.FindBelowWithChildrenNamed("/a", ".git", 1) {
+			names = append(names, s)
+		}
+		Ω(names).Should(ConsistOf("/a/acme/vendor"))
+		names = nil
+		for s := range finder.FindBelowNamed("/a", ".git", 1) {
+			names = append(names, s)
+		}
+		Ω(names).Should(ConsistOf("/a/acme/vendor/.git"))
+	})
+
+	It("lists no more directories at once than it has workers", func() {
+		counting := &countingLister{Lister: lister}
+		finder.Lister = counting
+		finder.Workers = 2
+		for range finder.FindBelowNamed("/a", "d", 0) {
+		}
+		Ω(counting.max).Should(BeNumerically("<=", 2))
+	})
+
+	It("can find several things at once", func() {
+		var wg sync.WaitGroup
+		results := make([][]string, 8)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for s := range finder.FindBelowWithChildrenNamed("/a", "d", 0) {
+					results[i] = append(results[i], s)
+				}
+			}(i)
+		}
+		wg.Wait()
+		for _, names := range results {
+			Ω(names).Should(ConsistOf("/a/c", "/a/b", "/a/c/d"))
+		}
+	})
+
+	It("stops walking when cancelled", func() {
+		// Every directory has another hundred in it, far too many to walk
+		wide := &countingLister{Lister: wideLister{}}
+		finder.Lister = wide
+		before := runtime.NumGoroutine()
+		ctx, cancel := context.WithCancel(context.Background())
+		found := finder.FindBelowNamedContext(ctx, "/", "d1", 0)
+		Ω(<-found).Should(HavePrefix("/"))
+		cancel()
+		Eventually(found).Should(BeClosed())
+		Eventually(runtime.NumGoroutine).Should(BeNumerically("<=", before))
+		wide.mu.Lock()
+		defer wide.mu.Unlock()
+		Ω(wide.total).Should(BeNumerically("<", 1000))
+	})
+
+	It("stops walking when cancelled while nobody is reading", func() {
+		finder.Lister = wideLister{}
+		before := runtime.NumGoroutine()
+		ctx, cancel := context.WithCancel(context.Background())
+		found := finder.FindBelowWithChildrenNamedContext(ctx, "/", "d1", 0)
+		Ω(<-found).Should(HavePrefix("/"))
+		cancel()
+		Eventually(runtime.NumGoroutine).Should(BeNumerically("<=", before))
+	})
+
+	Context("on disk", func() {
+
+		var tempdir string
+
+		mkdirs := func(dirs ...string) {
+			for _, dir := range dirs {
+				Ω(os.MkdirAll(filepath.Join(tempdir, dir), 0755)).Should(Succeed())
+			}
+		}
+
+		find := func() []string {
+			var names []string
+			for s := range DefaultFinder().FindBelowWithChildrenNamed(tempdir, ".git", 1) {
+				rel, _ := filepath.Rel(tempdir, s)
+				names = append(names, rel)
+			}
+			return names
+		}
+
+		BeforeEach(func() {
+			td, err := ioutil.TempDir("", "jig-")
+			Ω(err).ShouldNot(HaveOccurred())
+			tempdir = td
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tempdir)
+		})
+
+		It("skips what ignore files say to", func() {
+			mkdirs("a/repo/.git", "a/build-1/.git", "vendor/repo/.git", "b/old/repo/.git", "b/new/repo/.git")
+			Ω(ioutil.WriteFile(filepath.Join(tempdir, IgnoreFileName), []byte("# output\nbuild-*\n!vendor\n"), 0644)).Should(Succeed())
+			Ω(ioutil.WriteFile(filepath.Join(tempdir, "b", IgnoreFileName), []byte("old/repo\n"), 0644)).Should(Succeed())
+			Ω(find()).Should(ConsistOf("a/repo", "vendor/repo", "b/new/repo"))
+		})
+
+		It("follows ignore files above where it starts", func() {
+			mkdirs("set/a/repo/.git", "set/build-1/.git")
+			Ω(ioutil.WriteFile(filepath.Join(tempdir, IgnoreFileName), []byte("build-*\n"), 0644)).Should(Succeed())
+			var names []string
+			for s := range DefaultFinder().FindBelowWithChildrenNamed(filepath.Join(tempdir, "set"), ".git", 1) {
+				rel, _ := filepath.Rel(tempdir, s)
+				names = append(names, rel)
+			}
+			Ω(names).Should(ConsistOf("set/a/repo"))
+		})
+
+		It("doesn't go round symlink loops", func() {
+			mkdirs("a/repo/.git", "b")
+			Ω(os.Symlink(tempdir, filepath.Join(tempdir, "b", "loop"))).Should(Succeed())
+			Ω(os.Symlink(filepath.Join(tempdir, "a"), filepath.Join(tempdir, "b", "a"))).Should(Succeed())
+			names := find()
+			Ω(names).Should(HaveLen(1))
+			Ω([]string{"a/repo", "b/a/repo", "b/loop/a/repo"}).Should(ContainElement(names[0]))
+		})
+
+	})
+
 })
+
+// wideLister lists a hundred children in every directory
+type wideLister struct{}
+
+func (wideLister) ListChildren(path string) ([]string, error) {
+	names := make([]string, 100)
+	for i := range names {
+		names[i] = fmt.Sprintf("d%d", i)
+	}
+	return names, nil
+}
+
+// countingLister tracks the most directories listed at once, and how many
+// have been listed in all
+type countingLister struct {
+	Lister
+	mu                  sync.Mutex
+	current, max, total int
+}
+
+func (l *countingLister) ListChildren(path string) ([]string, error) {
+	l.mu.Lock()
+	l.total++
+	l.current++
+	if l.current > l.max {
+		l.max = l.current
+	}
+	l.mu.Unlock()
+	// Long enough for others to start
+	time.Sleep(time.Millisecond)
+	defer func() {
+		l.mu.Lock()
+		l.current--
+		l.mu.Unlock()
+	}()
+	return l.Lister.ListChildren(path)
+}
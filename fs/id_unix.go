@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package fs
+
+import (
+	"os"
+	"syscall"
+)
+
+// Identify satisfies the Identifier interface
+func (l *basicLister) Identify(path string) (FileID, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return FileID{}, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return FileID{}, errUnidentified
+	}
+	return FileID{uint64(stat.Dev), uint64(stat.Ino)}, nil
+}
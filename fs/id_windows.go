@@ -0,0 +1,7 @@
+package fs
+
+// Identify satisfies the Identifier interface. Windows has no inodes to go
+// by, so nothing is identified.
+func (l *basicLister) Identify(path string) (FileID, error) {
+	return FileID{}, errUnidentified
+}
@@ -0,0 +1,132 @@
+package fs
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFileName names the files of rules for what finders skip. Each line
+// is a glob matched against the name of a file or directory, or, if it has a
+// slash in it, against its path relative to the directory the file is in.
+// Rules apply to everything below that directory; a rule starting with ! takes
+// back what an earlier one, or the default skip list, skipped. Blank lines and
+// lines starting with # are ignored.
+const IgnoreFileName = ".jigignore"
+
+// DefaultSkip names the directories finders don't go into unless told
+// otherwise: dependencies and build output that never hold repositories worth
+// finding, and can be huge. A repository checked out to a directory with one
+// of these names is still found.
+var DefaultSkip = []string{
+	"node_modules",
+	"bower_components",
+	"jspm_packages",
+	"vendor",
+	"__pycache__",
+	".tox",
+	".venv",
+	".gradle",
+	"target",
+}
+
+// rule is a line of an ignore file
+type rule struct {
+	pattern string
+	negate  bool
+	// base is the directory the ignore file is in
+	base string
+}
+
+// Ignore is what a walk passes over in a directory: the names in a skip list,
+// and whatever the ignore files in the directory and those above it say
+type Ignore struct {
+	skip  []string
+	rules []rule
+}
+
+// NewIgnore returns what a walk starting at dir passes over because of the
+// ignore files above it, and a skip list; nil means DefaultSkip. The rules of
+// dir's own ignore file are added by Enter, as with every directory walked.
+func NewIgnore(dir string, skip []string) Ignore {
+	if skip == nil {
+		skip = DefaultSkip
+	}
+	ig := Ignore{skip: skip}
+	var above []string
+	for d := filepath.Dir(dir); ; d = filepath.Dir(d) {
+		above = append(above, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	for i := len(above) - 1; i >= 0; i-- {
+		ig.rules = append(ig.rules, readIgnoreFile(above[i])...)
+	}
+	return ig
+}
+
+// Enter returns what's passed over in dir, adding the rules of its ignore
+// file. It need only be called for directories that have one.
+func (ig Ignore) Enter(dir string) Ignore {
+	if rules := readIgnoreFile(dir); len(rules) > 0 {
+		ig.rules = append(append([]rule{}, ig.rules...), rules...)
+	}
+	return ig
+}
+
+// Skipped reports whether a walk passes over path. Ignore files have the last
+// say. Otherwise names in the skip list are passed over, unless path is what
+// the walk is looking for, like a repository someone has checked out to
+// github.com/acme/vendor.
+func (ig Ignore) Skipped(path string, match bool) bool {
+	name := filepath.Base(path)
+	var skipped bool
+	if !match {
+		for _, pattern := range ig.skip {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				skipped = true
+				break
+			}
+		}
+	}
+	for _, r := range ig.rules {
+		target := name
+		if strings.Contains(r.pattern, "/") {
+			rel, err := filepath.Rel(r.base, path)
+			if err != nil {
+				continue
+			}
+			target = filepath.ToSlash(rel)
+		}
+		if ok, _ := filepath.Match(r.pattern, target); ok {
+			skipped = !r.negate
+		}
+	}
+	return skipped
+}
+
+// readIgnoreFile reads the rules in the ignore file in a directory
+func readIgnoreFile(dir string) []rule {
+	data, err := ioutil.ReadFile(filepath.Join(dir, IgnoreFileName))
+	if err != nil {
+		return nil
+	}
+	rules := []rule{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r := rule{base: dir}
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		}
+		r.pattern = strings.Trim(line, "/")
+		if r.pattern != "" {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
@@ -0,0 +1,257 @@
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// RepoWatcher watches the working trees of repositories, including their .git
+// directories, and reports which repositories changed. Changes are debounced
+// per repository, and git writing objects doesn't count as a change.
+// Directories finders skip, like dependencies, build output and those named
+// in ignore files, aren't watched.
+type RepoWatcher struct {
+	// Changes receives the directory of a repository once it has been quiet
+	// for the debounce interval after changing
+	Changes chan string
+	// Errors receives errors from the underlying watcher
+	Errors chan error
+
+	watcher  *fsnotify.Watcher
+	debounce time.Duration
+	done     chan struct{}
+	mu       sync.Mutex
+	repos    []string
+	// ignores holds what's ignored in each directory watched
+	ignores map[string]Ignore
+	// incomplete holds the repositories with directories that couldn't be
+	// watched
+	incomplete map[string]bool
+}
+
+// NewRepoWatcher creates a RepoWatcher that waits for debounce after the last
+// change to a repository before reporting it
+func NewRepoWatcher(debounce time.Duration) (*RepoWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if debounce < 10*time.Millisecond {
+		debounce = 10 * time.Millisecond
+	}
+	w := &RepoWatcher{
+		Changes:    make(chan string),
+		Errors:     make(chan error),
+		watcher:    watcher,
+		debounce:   debounce,
+		done:       make(chan struct{}),
+		ignores:    map[string]Ignore{},
+		incomplete: map[string]bool{},
+	}
+	go w.loop()
+	return w, nil
+}
+
+// Watch starts watching the repository in dir. An error is returned if any
+// part of it can't be watched, which usually means the system limit on
+// watches has been reached, and the repository is no longer Complete.
+func (w *RepoWatcher) Watch(dir string) error {
+	dir = filepath.Clean(dir)
+	w.mu.Lock()
+	w.repos = append(w.repos, dir)
+	// Longest first, so nested repositories claim their own changes
+	sort.Slice(w.repos, func(i, j int) bool { return len(w.repos[i]) > len(w.repos[j]) })
+	w.mu.Unlock()
+	return w.addTree(dir, dir, NewIgnore(dir, nil))
+}
+
+// Unwatch stops watching the repository in dir, leaving any repositories
+// nested in it watched
+func (w *RepoWatcher) Unwatch(dir string) {
+	dir = filepath.Clean(dir)
+	w.mu.Lock()
+	paths := []string{}
+	for path := range w.ignores {
+		if w.owner(path) == dir {
+			paths = append(paths, path)
+			delete(w.ignores, path)
+		}
+	}
+	for i, repo := range w.repos {
+		if repo == dir {
+			w.repos = append(w.repos[:i], w.repos[i+1:]...)
+			break
+		}
+	}
+	delete(w.incomplete, dir)
+	w.mu.Unlock()
+	for _, path := range paths {
+		w.watcher.Remove(path)
+	}
+}
+
+// Complete reports whether every directory in the repository in dir that
+// should be watched is, so none of its changes go unseen
+func (w *RepoWatcher) Complete(dir string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return !w.incomplete[filepath.Clean(dir)]
+}
+
+// Close stops watching
+func (w *RepoWatcher) Close() error {
+	close(w.done)
+	return w.watcher.Close()
+}
+
+// addTree watches path and the directories below it that aren't skipped,
+// given what's ignored where path is
+func (w *RepoWatcher) addTree(repo, path string, ignore Ignore) error {
+	if ignored(repo, path) {
+		return nil
+	}
+	if err := w.watcher.Add(path); err != nil {
+		w.mu.Lock()
+		w.incomplete[repo] = true
+		w.mu.Unlock()
+		return err
+	}
+	infos, err := ioutil.ReadDir(path)
+	if err != nil {
+		// Gone already
+		return nil
+	}
+	for _, info := range infos {
+		if info.Name() == IgnoreFileName {
+			ignore = ignore.Enter(path)
+			break
+		}
+	}
+	w.mu.Lock()
+	w.ignores[path] = ignore
+	w.mu.Unlock()
+	var first error
+	for _, info := range infos {
+		p := filepath.Join(path, info.Name())
+		if !info.IsDir() || ignore.Skipped(p, false) {
+			continue
+		}
+		if err := w.addTree(repo, p, ignore); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// skipped reports whether a path is one the watcher leaves alone, and
+// returns what's ignored where it is
+func (w *RepoWatcher) skipped(path string) (bool, Ignore) {
+	w.mu.Lock()
+	ignore, ok := w.ignores[filepath.Dir(path)]
+	w.mu.Unlock()
+	return ok && ignore.Skipped(path, false), ignore
+}
+
+// repoFor returns the repository a path belongs to, or ""
+func (w *RepoWatcher) repoFor(path string) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.owner(path)
+}
+
+// owner is repoFor for callers holding the lock
+func (w *RepoWatcher) owner(path string) string {
+	for _, repo := range w.repos {
+		if path == repo || strings.HasPrefix(path, repo+string(filepath.Separator)) {
+			return repo
+		}
+	}
+	return ""
+}
+
+// ignored reports whether a path in a repository changes without the status
+// of the repository changing: the object store, and lock files git writes
+// before renaming them into place.
+func ignored(repo, path string) bool {
+	rel, err := filepath.Rel(repo, path)
+	if err != nil {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] == "objects" && parts[i-1] == ".git" {
+			return true
+		}
+	}
+	return strings.HasSuffix(path, ".lock")
+}
+
+func (w *RepoWatcher) loop() {
+	defer close(w.Changes)
+	pending := map[string]time.Time{}
+	tick := time.NewTicker(w.debounce / 2)
+	defer tick.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case ev, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			repo := w.repoFor(ev.Name)
+			if repo == "" || ignored(repo, ev.Name) {
+				continue
+			}
+			skipped, ignore := w.skipped(ev.Name)
+			if skipped {
+				continue
+			}
+			if ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				w.mu.Lock()
+				delete(w.ignores, ev.Name)
+				w.mu.Unlock()
+			}
+			if ev.Op&fsnotify.Create != 0 {
+				if info, err := os.Lstat(ev.Name); err == nil && info.IsDir() {
+					if err := w.addTree(repo, ev.Name, ignore); err != nil {
+						select {
+						case w.Errors <- err:
+						case <-w.done:
+							return
+						}
+					}
+				}
+			}
+			pending[repo] = time.Now()
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			select {
+			case w.Errors <- err:
+			case <-w.done:
+				return
+			}
+		case now := <-tick.C:
+			for repo, last := range pending {
+				if now.Sub(last) < w.debounce {
+					continue
+				}
+				delete(pending, repo)
+				select {
+				case w.Changes <- repo:
+				case <-w.done:
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package fs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/iancmcc/jig/fs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RepoWatcher", func() {
+
+	var (
+		tempdir  string
+		one, two string
+		watcher  *RepoWatcher
+		touch    func(path string)
+	)
+
+	BeforeEach(func() {
+		td, err := ioutil.TempDir("", "jig-")
+		Expect(err).To(BeNil())
+		tempdir, _ = filepath.EvalSymlinks(td)
+		one = filepath.Join(tempdir, "one")
+		two = filepath.Join(tempdir, "two")
+		for _, repo := range []string{one, two} {
+			Expect(os.MkdirAll(filepath.Join(repo, ".git", "objects", "ab"), 0755)).To(BeNil())
+			Expect(os.MkdirAll(filepath.Join(repo, "src"), 0755)).To(BeNil())
+		}
+		touch = func(path string) {
+			Expect(ioutil.WriteFile(path, []byte("x"), 0644)).To(BeNil())
+		}
+		watcher, err = NewRepoWatcher(20 * time.Millisecond)
+		Expect(err).To(BeNil())
+		Expect(watcher.Watch(one)).To(BeNil())
+		Expect(watcher.Watch(two)).To(BeNil())
+	})
+
+	AfterEach(func() {
+		watcher.Close()
+		os.RemoveAll(tempdir)
+	})
+
+	It("reports the repository whose working tree changed", func() {
+		touch(filepath.Join(one, "src", "main.go"))
+		Eventually(watcher.Changes).Should(Receive(Equal(one)))
+		Consistently(watcher.Changes, 200*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("reports changes to the git directory", func() {
+		touch(filepath.Join(two, ".git", "HEAD"))
+		Eventually(watcher.Changes).Should(Receive(Equal(two)))
+	})
+
+	It("reports a burst of changes once", func() {
+		for _, name := range []string{"a", "b", "c", "d"} {
+			touch(filepath.Join(one, name))
+		}
+		Eventually(watcher.Changes).Should(Receive(Equal(one)))
+		Consistently(watcher.Changes, 200*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("ignores git writing objects and lock files", func() {
+		touch(filepath.Join(one, ".git", "objects", "ab", "cdef"))
+		touch(filepath.Join(one, ".git", "index.lock"))
+		Consistently(watcher.Changes, 200*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("doesn't watch dependencies or what ignore files skip", func() {
+		three := filepath.Join(tempdir, "three")
+		for _, dir := range []string{"node_modules/pkg", "build/out", "logs", "src"} {
+			Expect(os.MkdirAll(filepath.Join(three, dir), 0755)).To(BeNil())
+		}
+		Expect(ioutil.WriteFile(filepath.Join(tempdir, IgnoreFileName), []byte("logs\n"), 0644)).To(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(three, IgnoreFileName), []byte("build\n"), 0644)).To(BeNil())
+		Expect(watcher.Watch(three)).To(BeNil())
+		Expect(watcher.Complete(three)).To(BeTrue())
+		touch(filepath.Join(three, "node_modules", "pkg", "index.js"))
+		touch(filepath.Join(three, "build", "out", "main.o"))
+		touch(filepath.Join(three, "logs", "today.log"))
+		Expect(os.Mkdir(filepath.Join(three, "node_modules", "other"), 0755)).To(BeNil())
+		Consistently(watcher.Changes, 200*time.Millisecond).ShouldNot(Receive())
+		touch(filepath.Join(three, "src", "main.go"))
+		Eventually(watcher.Changes).Should(Receive(Equal(three)))
+	})
+
+	It("stops reporting a repository once it's unwatched", func() {
+		watcher.Unwatch(one)
+		touch(filepath.Join(one, "src", "main.go"))
+		Consistently(watcher.Changes, 200*time.Millisecond).ShouldNot(Receive())
+		touch(filepath.Join(two, "src", "main.go"))
+		Eventually(watcher.Changes).Should(Receive(Equal(two)))
+	})
+
+	It("watches directories created after it started", func() {
+		dir := filepath.Join(two, "src", "pkg")
+		Expect(os.Mkdir(dir, 0755)).To(BeNil())
+		Eventually(watcher.Changes).Should(Receive(Equal(two)))
+		touch(filepath.Join(dir, "file.go"))
+		Eventually(watcher.Changes).Should(Receive(Equal(two)))
+	})
+
+})
@@ -0,0 +1,67 @@
+package hosting
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultGitHubURL is the API of github.com. GitHub Enterprise serves its API
+// at https://HOST/api/v3.
+const DefaultGitHubURL = "https://api.github.com"
+
+// GitHub lists the repositories of a GitHub organization
+type GitHub struct {
+	// BaseURL is the root of the REST API
+	BaseURL string
+	// Token authenticates requests, so private repositories are listed too
+	Token  string
+	Client *http.Client
+}
+
+type githubRepo struct {
+	Name          string   `json:"name"`
+	FullName      string   `json:"full_name"`
+	CloneURL      string   `json:"clone_url"`
+	DefaultBranch string   `json:"default_branch"`
+	Topics        []string `json:"topics"`
+	Archived      bool     `json:"archived"`
+}
+
+// List satisfies the Lister interface
+func (g *GitHub) List(org string) ([]*Repo, error) {
+	base := g.BaseURL
+	if base == "" {
+		base = DefaultGitHubURL
+	}
+	header := http.Header{}
+	header.Set("Accept", "application/vnd.github+json")
+	if g.Token != "" {
+		header.Set("Authorization", "token "+g.Token)
+	}
+	repos := []*Repo{}
+	u := strings.TrimSuffix(base, "/") + "/orgs/" + url.PathEscape(org) + "/repos?type=all&per_page=100"
+	err := getPages(g.Client, u, header, func(r io.Reader) error {
+		var page []githubRepo
+		if err := json.NewDecoder(r).Decode(&page); err != nil {
+			return err
+		}
+		for _, gr := range page {
+			repos = append(repos, &Repo{
+				Name:          gr.Name,
+				Path:          gr.FullName,
+				CloneURL:      gr.CloneURL,
+				DefaultBranch: gr.DefaultBranch,
+				Topics:        gr.Topics,
+				Archived:      gr.Archived,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
@@ -0,0 +1,73 @@
+package hosting
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultGitLabURL is the API of gitlab.com. Self-managed GitLab serves its
+// API at https://HOST/api/v4.
+const DefaultGitLabURL = "https://gitlab.com/api/v4"
+
+// GitLab lists the projects of a GitLab group, including its subgroups
+type GitLab struct {
+	// BaseURL is the root of the REST API
+	BaseURL string
+	// Token authenticates requests, so private projects are listed too
+	Token  string
+	Client *http.Client
+}
+
+type gitlabProject struct {
+	Path              string   `json:"path"`
+	PathWithNamespace string   `json:"path_with_namespace"`
+	HTTPURLToRepo     string   `json:"http_url_to_repo"`
+	DefaultBranch     string   `json:"default_branch"`
+	Topics            []string `json:"topics"`
+	// TagList is what GitLab called topics before 14.0
+	TagList  []string `json:"tag_list"`
+	Archived bool     `json:"archived"`
+}
+
+// List satisfies the Lister interface
+func (g *GitLab) List(group string) ([]*Repo, error) {
+	base := g.BaseURL
+	if base == "" {
+		base = DefaultGitLabURL
+	}
+	header := http.Header{}
+	if g.Token != "" {
+		header.Set("PRIVATE-TOKEN", g.Token)
+	}
+	repos := []*Repo{}
+	// Groups are addressed by their full path, slashes and all, encoded
+	u := strings.TrimSuffix(base, "/") + "/groups/" + url.PathEscape(group) + "/projects?include_subgroups=true&per_page=100"
+	err := getPages(g.Client, u, header, func(r io.Reader) error {
+		var page []gitlabProject
+		if err := json.NewDecoder(r).Decode(&page); err != nil {
+			return err
+		}
+		for _, p := range page {
+			topics := p.Topics
+			if len(topics) == 0 {
+				topics = p.TagList
+			}
+			repos = append(repos, &Repo{
+				Name:          p.Path,
+				Path:          p.PathWithNamespace,
+				CloneURL:      p.HTTPURLToRepo,
+				DefaultBranch: p.DefaultBranch,
+				Topics:        topics,
+				Archived:      p.Archived,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
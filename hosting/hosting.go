@@ -0,0 +1,126 @@
+package hosting
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Repo is a repository listed by a hosting service
+type Repo struct {
+	// Name is the name of the repository within its organization or group
+	Name string
+	// Path is the full path of the repository on the host, e.g. "org/repo"
+	Path     string
+	CloneURL string
+	// DefaultBranch is empty for repositories without any commits
+	DefaultBranch string
+	Topics        []string
+	Archived      bool
+}
+
+// Lister lists the repositories belonging to an organization or group
+type Lister interface {
+	List(org string) ([]*Repo, error)
+}
+
+// Filter selects some of the repositories listed
+type Filter struct {
+	// Topics, if any, must all be topics of a repository
+	Topics []string
+	// Archived includes archived repositories, which are left out otherwise
+	Archived bool
+	// Name, if set, must match the name of a repository
+	Name *regexp.Regexp
+}
+
+// Match reports whether a repository passes the filter
+func (f Filter) Match(r *Repo) bool {
+	if r.Archived && !f.Archived {
+		return false
+	}
+	if f.Name != nil && !f.Name.MatchString(r.Name) {
+		return false
+	}
+	for _, want := range f.Topics {
+		var found bool
+		for _, topic := range r.Topics {
+			if strings.EqualFold(topic, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns the repositories that pass the filter
+func (f Filter) Apply(repos []*Repo) []*Repo {
+	matched := []*Repo{}
+	for _, r := range repos {
+		if f.Match(r) {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
+// nextLink matches the URL of the next page in a Link header
+var nextLink = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="next"`)
+
+// getPages GETs url and every page after it, as given by the Link headers
+// both GitHub and GitLab send, decoding each page with decode
+func getPages(client *http.Client, url string, header http.Header, decode func(io.Reader) error) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	for url != "" {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+			resp.Body.Close()
+			if msg := apiMessage(body); msg != "" {
+				return fmt.Errorf("GET %s: %s: %s", url, resp.Status, msg)
+			}
+			return fmt.Errorf("GET %s: %s", url, resp.Status)
+		}
+		err = decode(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		url = ""
+		if m := nextLink.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+			url = m[1]
+		}
+	}
+	return nil
+}
+
+// apiMessage pulls the message out of an API error body, if there is one
+func apiMessage(body []byte) string {
+	var e struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &e) != nil {
+		return ""
+	}
+	return e.Message
+}
@@ -0,0 +1,134 @@
+package hosting_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+
+	. "github.com/iancmcc/jig/hosting"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Hosting", func() {
+
+	var (
+		server *httptest.Server
+		mux    *http.ServeMux
+	)
+
+	BeforeEach(func() {
+		mux = http.NewServeMux()
+		server = httptest.NewServer(mux)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("GitHub", func() {
+
+		BeforeEach(func() {
+			mux.HandleFunc("/api/v3/orgs/acme/repos", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Header.Get("Authorization")).To(Equal("token secret"))
+				if r.URL.Query().Get("page") == "2" {
+					fmt.Fprint(w, `[{"name":"old","full_name":"acme/old","clone_url":"https://ghe.example.com/acme/old.git","default_branch":"master","archived":true}]`)
+					return
+				}
+				w.Header().Set("Link", fmt.Sprintf(`<%s/api/v3/orgs/acme/repos?page=2>; rel="next", <%s/api/v3/orgs/acme/repos?page=2>; rel="last"`, server.URL, server.URL))
+				fmt.Fprint(w, `[
+					{"name":"api","full_name":"acme/api","clone_url":"https://ghe.example.com/acme/api.git","default_branch":"main","topics":["go","service"]},
+					{"name":"web","full_name":"acme/web","clone_url":"https://ghe.example.com/acme/web.git","default_branch":"develop","topics":["js"]}
+				]`)
+			})
+		})
+
+		It("should list every page of an organization's repositories", func() {
+			gh := &GitHub{BaseURL: server.URL + "/api/v3", Token: "secret"}
+			repos, err := gh.List("acme")
+			Expect(err).To(BeNil())
+			Expect(repos).To(HaveLen(3))
+			Expect(*repos[0]).To(Equal(Repo{
+				Name:          "api",
+				Path:          "acme/api",
+				CloneURL:      "https://ghe.example.com/acme/api.git",
+				DefaultBranch: "main",
+				Topics:        []string{"go", "service"},
+			}))
+			Expect(repos[2].Archived).To(BeTrue())
+		})
+
+		It("should report API errors", func() {
+			gh := &GitHub{BaseURL: server.URL + "/api/v3", Token: "secret"}
+			mux.HandleFunc("/api/v3/orgs/nobody/repos", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"message":"Not Found"}`)
+			})
+			_, err := gh.List("nobody")
+			Expect(err).To(MatchError(ContainSubstring("404 Not Found: Not Found")))
+		})
+
+	})
+
+	Context("GitLab", func() {
+
+		It("should list the projects of a group and its subgroups", func() {
+			mux.HandleFunc("/api/v4/groups/", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.EscapedPath()).To(Equal("/api/v4/groups/acme%2Fplatform/projects"))
+				Expect(r.URL.Query().Get("include_subgroups")).To(Equal("true"))
+				Expect(r.Header.Get("PRIVATE-TOKEN")).To(Equal("secret"))
+				fmt.Fprint(w, `[
+					{"path":"infra","path_with_namespace":"acme/platform/infra","http_url_to_repo":"https://gitlab.example.com/acme/platform/infra.git","default_branch":"main","topics":["ops"]},
+					{"path":"legacy","path_with_namespace":"acme/platform/tools/legacy","http_url_to_repo":"https://gitlab.example.com/acme/platform/tools/legacy.git","default_branch":"master","tag_list":["ops"],"archived":true}
+				]`)
+			})
+			gl := &GitLab{BaseURL: server.URL + "/api/v4", Token: "secret"}
+			repos, err := gl.List("acme/platform")
+			Expect(err).To(BeNil())
+			Expect(repos).To(HaveLen(2))
+			Expect(repos[0].Path).To(Equal("acme/platform/infra"))
+			Expect(repos[1].Topics).To(Equal([]string{"ops"}))
+			Expect(repos[1].Archived).To(BeTrue())
+		})
+
+	})
+
+	Context("Filter", func() {
+
+		repos := []*Repo{
+			{Name: "api", Topics: []string{"go", "service"}},
+			{Name: "api-docs", Topics: []string{"docs"}},
+			{Name: "web", Topics: []string{"JS", "service"}},
+			{Name: "old-api", Topics: []string{"go"}, Archived: true},
+		}
+		names := func(rs []*Repo) []string {
+			out := []string{}
+			for _, r := range rs {
+				out = append(out, r.Name)
+			}
+			return out
+		}
+
+		It("should leave out archived repositories unless asked", func() {
+			Expect(names(Filter{}.Apply(repos))).To(Equal([]string{"api", "api-docs", "web"}))
+			Expect(names(Filter{Archived: true}.Apply(repos))).To(HaveLen(4))
+		})
+
+		It("should require every topic", func() {
+			Expect(names(Filter{Topics: []string{"service"}}.Apply(repos))).To(Equal([]string{"api", "web"}))
+			Expect(names(Filter{Topics: []string{"go", "service"}}.Apply(repos))).To(Equal([]string{"api"}))
+			Expect(names(Filter{Topics: []string{"js"}}.Apply(repos))).To(Equal([]string{"web"}))
+		})
+
+		It("should match names against a regular expression", func() {
+			f := Filter{Name: regexp.MustCompile(`api`), Archived: true}
+			Expect(names(f.Apply(repos))).To(Equal([]string{"api", "api-docs", "old-api"}))
+			f = Filter{Name: regexp.MustCompile(`^api$`)}
+			Expect(names(f.Apply(repos))).To(Equal([]string{"api"}))
+		})
+
+	})
+
+})
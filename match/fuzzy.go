@@ -0,0 +1,194 @@
+package match
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Scores for fuzzy matching, in the spirit of fzf. Every matched character
+// scores scoreMatch, plus a bonus depending on where it falls; gaps between
+// matched characters cost a little to open and less to extend.
+const (
+	scoreMatch        = 16
+	scoreGapStart     = -3
+	scoreGapExtension = -1
+	// bonusBoundary is for a character starting a path segment
+	bonusBoundary = 8
+	// bonusDelimiter is for a character following - _ or .
+	bonusDelimiter = 7
+	// bonusCamel is for an upper case character following a lower case one
+	bonusCamel = 7
+	// bonusConsecutive is for a character following the one matched before it
+	bonusConsecutive = 5
+	// bonusName is for a character in the last segment of the path, which
+	// names the repository, rather than the owner or domain
+	bonusName = 4
+	// bonusFirstCharMultiplier weighs the bonus of the first character
+	// matched, so queries starting at a boundary win
+	bonusFirstCharMultiplier = 2
+)
+
+// FuzzyPathMatcher matches paths that contain the characters of the query in
+// order, though not necessarily together, and ranks them by how well they
+// match
+type FuzzyPathMatcher struct {
+	query  string
+	values []string
+}
+
+// NewFuzzyPathMatcher returns a FuzzyPathMatcher for query. Matching ignores
+// case unless the query has upper case characters in it.
+func NewFuzzyPathMatcher(query string) *FuzzyPathMatcher {
+	return &FuzzyPathMatcher{query: query}
+}
+
+// Add satisfies the Matcher interface
+func (m *FuzzyPathMatcher) Add(s string) {
+	m.values = append(m.values, s)
+}
+
+// Match satisfies the Matcher interface
+func (m *FuzzyPathMatcher) Match() []string {
+	return values(m.Results())
+}
+
+// Results satisfies the Scorer interface
+func (m *FuzzyPathMatcher) Results() []Result {
+	type result struct {
+		Result
+		namelen int
+	}
+	results := []result{}
+	for _, value := range m.values {
+		score, ok := FuzzyScore(m.query, value)
+		if !ok {
+			continue
+		}
+		path := strings.Trim(value, "/")
+		results = append(results, result{Result{value, float64(score)}, len(path) - strings.LastIndex(path, "/") - 1})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		// Between equally good matches, the shortest name is most likely the
+		// one meant
+		if a.namelen != b.namelen {
+			return a.namelen < b.namelen
+		}
+		if len(a.Value) != len(b.Value) {
+			return len(a.Value) < len(b.Value)
+		}
+		return a.Value < b.Value
+	})
+	scored := make([]Result, len(results))
+	for i, r := range results {
+		scored[i] = r.Result
+	}
+	return scored
+}
+
+// bonus is the bonus for matching the character at i of text
+func bonus(text []rune, i int) int {
+	if i == 0 {
+		return bonusBoundary
+	}
+	prev, cur := text[i-1], text[i]
+	switch {
+	case prev == '/':
+		return bonusBoundary
+	case prev == '-' || prev == '_' || prev == '.':
+		return bonusDelimiter
+	case unicode.IsLower(prev) && unicode.IsUpper(cur):
+		return bonusCamel
+	}
+	return 0
+}
+
+// FuzzyScore scores how well query matches path, reporting false if the
+// characters of query don't all appear in path in order. The score is that
+// of the best way of matching them.
+func FuzzyScore(query, path string) (int, bool) {
+	q := []rune(query)
+	text := []rune(strings.Trim(path, "/"))
+	if len(q) == 0 {
+		return 0, true
+	}
+	if len(q) > len(text) {
+		return 0, false
+	}
+	norm := text
+	if query == strings.ToLower(query) {
+		norm = make([]rune, len(text))
+		for i, r := range text {
+			norm[i] = unicode.ToLower(r)
+		}
+	}
+	namestart := 0
+	for i, r := range text {
+		if r == '/' {
+			namestart = i + 1
+		}
+	}
+
+	const none = -1 << 30
+	n := len(text)
+	// prev[j] is the best score for the query so far with its last character
+	// matched at j
+	prev := make([]int, n)
+	cur := make([]int, n)
+	for j := range prev {
+		prev[j] = none
+	}
+	for i, qc := range q {
+		// gap is the best score for the previous character matched before
+		// j-1, less the cost of the gap up to j
+		gap := none
+		for j := 0; j < n; j++ {
+			if i > 0 && j >= 2 {
+				if gap != none {
+					gap += scoreGapExtension
+				}
+				if prev[j-2] != none && prev[j-2]+scoreGapStart > gap {
+					gap = prev[j-2] + scoreGapStart
+				}
+			}
+			cur[j] = none
+			if norm[j] != qc {
+				continue
+			}
+			score := scoreMatch + bonus(text, j)
+			if i == 0 {
+				score += bonus(text, j) * (bonusFirstCharMultiplier - 1)
+			}
+			if j >= namestart {
+				score += bonusName
+			}
+			switch {
+			case i == 0:
+				cur[j] = score
+			default:
+				best := gap
+				if j >= 1 && prev[j-1] != none && prev[j-1]+bonusConsecutive > best {
+					best = prev[j-1] + bonusConsecutive
+				}
+				if best != none {
+					cur[j] = best + score
+				}
+			}
+		}
+		prev, cur = cur, prev
+	}
+	best := none
+	for _, s := range prev {
+		if s > best {
+			best = s
+		}
+	}
+	if best == none {
+		return 0, false
+	}
+	return best, true
+}
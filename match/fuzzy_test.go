@@ -0,0 +1,96 @@
+package match_test
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	. "github.com/iancmcc/jig/match"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// readLines reads a testdata file, skipping blank lines and comments
+func readLines(name string) []string {
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	lines := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+var _ = Describe("FuzzyPathMatcher", func() {
+
+	It("should match characters in order, with gaps", func() {
+		_, ok := FuzzyScore("jg", "github.com/iancmcc/jig")
+		Ω(ok).Should(BeTrue())
+		_, ok = FuzzyScore("gj", "jig")
+		Ω(ok).Should(BeFalse())
+	})
+
+	It("should score consecutive characters above scattered ones", func() {
+		together, _ := FuzzyScore("jig", "a/jigsaw")
+		apart, _ := FuzzyScore("jig", "a/jxixg")
+		Ω(together).Should(BeNumerically(">", apart))
+	})
+
+	It("should score matches at separators above matches inside words", func() {
+		boundary, _ := FuzzyScore("gm", "onsi/go-mega")
+		inside, _ := FuzzyScore("gm", "onsi/ogxmxa")
+		Ω(boundary).Should(BeNumerically(">", inside))
+	})
+
+	It("should score matches in the repository name above the owner", func() {
+		name, _ := FuzzyScore("ab", "x/y/ab")
+		owner, _ := FuzzyScore("ab", "x/ab/y")
+		Ω(name).Should(BeNumerically(">", owner))
+	})
+
+	It("should be the default", func() {
+		Ω(DefaultMatcher("jig")).Should(BeAssignableToTypeOf(&FuzzyPathMatcher{}))
+		_, err := NewMatcher("bogus", "jig")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	Describe("the ranking corpus", func() {
+		corpus := []string{}
+		for _, path := range readLines("corpus.txt") {
+			// ls passes paths relative to the root, with a leading slash
+			corpus = append(corpus, "/"+path)
+		}
+		for _, line := range readLines("rankings.txt") {
+			parts := strings.SplitN(line, ":", 2)
+			query := strings.TrimSpace(parts[0])
+			expected := []string{}
+			for _, path := range strings.Split(parts[1], ",") {
+				if path = strings.TrimSpace(path); path != "" {
+					expected = append(expected, "/"+path)
+				}
+			}
+			It("should rank "+query, func() {
+				matcher := DefaultMatcher(query)
+				for _, path := range corpus {
+					matcher.Add(path)
+				}
+				results := matcher.Match()
+				if len(expected) == 0 {
+					Ω(results).Should(BeEmpty())
+					return
+				}
+				Ω(len(results)).Should(BeNumerically(">=", len(expected)))
+				Ω(results[:len(expected)]).Should(Equal(expected))
+			})
+		}
+	})
+
+})
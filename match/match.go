@@ -1,6 +1,7 @@
 package match
 
 import (
+	"fmt"
 	"sort"
 	"strings"
 
@@ -12,7 +13,21 @@ type Matcher interface {
 	Match() []string
 }
 
+// Matchers are the names of the matchers NewMatcher knows, the default first
+var Matchers = []string{"fuzzy", "substring", "levenshtein", "jarowinkler"}
+
+// DefaultMatcher returns the fuzzy matcher for query
 func DefaultMatcher(query string) Matcher {
+	return NewFuzzyPathMatcher(query)
+}
+
+// NewMatcher returns the matcher with a name for query, or an error if there
+// is no such matcher
+func NewMatcher(name, query string) (Matcher, error) {
+	if name == "fuzzy" || name == "" {
+		return NewFuzzyPathMatcher(query), nil
+	}
+	// The older matchers compare against paths with their separators removed
 	query = strings.Map(func(r rune) rune {
 		switch {
 		case r == '/' || r == '-' || r == '.':
@@ -21,24 +36,26 @@ func DefaultMatcher(query string) Matcher {
 			return r
 		}
 	}, query)
-	return &SubstringPathMatcher{query: query}
-	/*
+	switch name {
+	case "substring":
+		return &SubstringPathMatcher{query: query}, nil
+	case "levenshtein":
 		return &LevenshteinPathMatcher{
 			query:            query,
 			insertionCost:    1,
 			deletionCost:     1,
 			substitutionCost: 2,
-		}
-	*/
-	/*
+		}, nil
+	case "jarowinkler":
 		return &JaroWinklerPathMatcher{
 			query:          query,
 			minScore:       0.5,
 			inbox:          make(chan string),
 			boostThreshold: 0.7,
 			prefixSize:     4,
-		}
-	*/
+		}, nil
+	}
+	return nil, fmt.Errorf("no matcher named %q; use one of %s", name, strings.Join(Matchers, ", "))
 }
 
 type Scored struct {
@@ -58,6 +75,15 @@ func (s ScoredArray) ToStringArray() (result []string) {
 	return
 }
 
+// ToResults converts scores, which higher is better of, into Results
+func (s ScoredArray) ToResults() []Result {
+	results := make([]Result, len(s))
+	for i, x := range s {
+		results[i] = Result{x.value, x.score}
+	}
+	return results
+}
+
 type Value struct {
 	key   string
 	value string
@@ -79,7 +105,6 @@ type JaroWinklerPathMatcher struct {
 	inbox          chan string
 	boostThreshold float64
 	prefixSize     int
-	scores         ScoredArray
 }
 
 type SubstringPathMatcher struct {
@@ -109,6 +134,11 @@ func (m *JaroWinklerPathMatcher) Add(s string) {
 
 // Match returns the strings previously Added in sorted order
 func (m *JaroWinklerPathMatcher) Match() []string {
+	return values(m.Results())
+}
+
+// Results satisfies the Scorer interface
+func (m *JaroWinklerPathMatcher) Results() []Result {
 	results := map[string]*Scored{}
 	for _, value := range m.allstrings {
 		score := smetrics.JaroWinkler(m.query, value.value, m.boostThreshold, m.prefixSize)
@@ -117,14 +147,21 @@ func (m *JaroWinklerPathMatcher) Match() []string {
 			results[value.key] = &Scored{value.key, score}
 		}
 	}
+	var scores ScoredArray
 	for _, v := range results {
-		m.scores = append(m.scores, v)
+		scores = append(scores, v)
 	}
-	sort.Sort(m.scores)
-	return m.scores.ToStringArray()
+	sort.Sort(scores)
+	return scores.ToResults()
 }
 
 func (m *LevenshteinPathMatcher) Match() []string {
+	return values(m.Results())
+}
+
+// Results satisfies the Scorer interface. Scores fall from 1 for an exact
+// match as the distance grows.
+func (m *LevenshteinPathMatcher) Results() []Result {
 	results := map[string]*Scored{}
 	for _, value := range m.allstrings {
 		score := -float64(smetrics.WagnerFischer(m.query, value.value, m.insertionCost, m.deletionCost, m.substitutionCost))
@@ -139,7 +176,11 @@ func (m *LevenshteinPathMatcher) Match() []string {
 		scores = append(scores, v)
 	}
 	sort.Sort(scores)
-	return scores.ToStringArray()
+	ranked := scores.ToResults()
+	for i := range ranked {
+		ranked[i].Score = 1 / (1 - ranked[i].Score)
+	}
+	return ranked
 }
 
 func (m *LevenshteinPathMatcher) Add(s string) {
@@ -157,6 +198,11 @@ func (m *LevenshteinPathMatcher) Add(s string) {
 }
 
 func (m *SubstringPathMatcher) Match() []string {
+	return values(m.Results())
+}
+
+// Results satisfies the Scorer interface
+func (m *SubstringPathMatcher) Results() []Result {
 	results := map[string]*Scored{}
 	smartCase := m.query != strings.ToLower(m.query)
 	for _, value := range m.allstrings {
@@ -176,7 +222,7 @@ func (m *SubstringPathMatcher) Match() []string {
 		scores = append(scores, v)
 	}
 	sort.Sort(scores)
-	return scores.ToStringArray()
+	return scores.ToResults()
 }
 
 func (m *SubstringPathMatcher) Add(s string) {
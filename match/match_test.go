@@ -21,14 +21,17 @@ var (
 )
 
 func testMatcher(query string) *SubstringPathMatcher {
-	matcher := DefaultMatcher(query)
+	matcher, err := NewMatcher("substring", query)
+	if err != nil {
+		panic(err)
+	}
 	for _, s := range paths {
 		matcher.Add(s)
 	}
 	return matcher.(*SubstringPathMatcher)
 }
 
-var _ = Describe("DefaultPathMatcher", func() {
+var _ = Describe("SubstringPathMatcher", func() {
 
 	It("should match an exact match", func() {
 		results := testMatcher("github.com/iancmcc/jig").Match()
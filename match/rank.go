@@ -0,0 +1,74 @@
+package match
+
+import "sort"
+
+// frecencyWeight is how far frecency can carry a match: the repository
+// visited most can overtake a match up to this fraction better than its own,
+// so it mostly settles matches that are close
+const frecencyWeight = 0.1
+
+// Result is a match and how well it matched; higher is better
+type Result struct {
+	Value string
+	Score float64
+}
+
+// Scorer is a Matcher that can say how well each match matched
+type Scorer interface {
+	Matcher
+	Results() []Result
+}
+
+// values returns the values of results, in order
+func values(results []Result) []string {
+	matched := make([]string, len(results))
+	for i, r := range results {
+		matched[i] = r.Value
+	}
+	return matched
+}
+
+// Rank orders the matches of m, letting those with a high frecency move ahead
+// of slightly better matches. Matchers that aren't Scorers keep their order.
+func Rank(m Matcher, frecency func(string) float64) []string {
+	s, ok := m.(Scorer)
+	if !ok {
+		return m.Match()
+	}
+	results := s.Results()
+	if len(results) == 0 {
+		return []string{}
+	}
+	best := results[0].Score
+	freq := make([]float64, len(results))
+	var maxfreq float64
+	for i, r := range results {
+		if r.Score > best {
+			best = r.Score
+		}
+		freq[i] = frecency(r.Value)
+		if freq[i] > maxfreq {
+			maxfreq = freq[i]
+		}
+	}
+	ranks := make([]float64, len(results))
+	for i, r := range results {
+		if best > 0 && r.Score > 0 {
+			ranks[i] = r.Score / best
+		}
+		if maxfreq > 0 {
+			ranks[i] += frecencyWeight * freq[i] / maxfreq
+		}
+	}
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	// Stable, so equal ranks keep the matcher's order
+	sort.SliceStable(order, func(i, j int) bool { return ranks[order[i]] > ranks[order[j]] })
+	ranked := make([]string, len(results))
+	for i, o := range order {
+		ranked[i] = results[o].Value
+	}
+	return ranked
+}
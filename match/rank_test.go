@@ -0,0 +1,64 @@
+package match_test
+
+import (
+	. "github.com/iancmcc/jig/match"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rank", func() {
+
+	rank := func(name, query string, frecency map[string]float64, values ...string) []string {
+		m, err := NewMatcher(name, query)
+		Expect(err).To(BeNil())
+		for _, v := range values {
+			m.Add(v)
+		}
+		return Rank(m, func(v string) float64 { return frecency[v] })
+	}
+
+	It("should keep the matcher's order without any history", func() {
+		Expect(rank("fuzzy", "jig", nil, "/github.com/iancmcc/jigsaw", "/github.com/iancmcc/jig")).To(Equal([]string{
+			"/github.com/iancmcc/jig",
+			"/github.com/iancmcc/jigsaw",
+		}))
+	})
+
+	It("should favor a frequent match over a slightly better one", func() {
+		Expect(rank("fuzzy", "jig", map[string]float64{"/github.com/iancmcc/jigsaw": 10},
+			"/github.com/iancmcc/jigsaw", "/github.com/iancmcc/jig")).To(Equal([]string{
+			"/github.com/iancmcc/jigsaw",
+			"/github.com/iancmcc/jig",
+		}))
+	})
+
+	It("should not favor a frequent match over a much better one", func() {
+		Expect(rank("fuzzy", "jig", map[string]float64{"/github.com/jane/ideas-go": 10},
+			"/github.com/jane/ideas-go", "/github.com/iancmcc/jig")).To(Equal([]string{
+			"/github.com/iancmcc/jig",
+			"/github.com/jane/ideas-go",
+		}))
+	})
+
+	It("should use the history with every matcher", func() {
+		for _, name := range Matchers {
+			Expect(rank(name, "jig", map[string]float64{"/github.com/jane/jig": 1},
+				"/github.com/iancmcc/jig", "/github.com/jane/jig")[0]).To(Equal("/github.com/jane/jig"), name)
+			Expect(rank(name, "jig", map[string]float64{"/github.com/iancmcc/jig": 1},
+				"/github.com/iancmcc/jig", "/github.com/jane/jig")[0]).To(Equal("/github.com/iancmcc/jig"), name)
+		}
+	})
+
+	It("should not favor a frequent match over a much better one with the other matchers", func() {
+		for _, name := range []string{"substring", "levenshtein", "jarowinkler"} {
+			Expect(rank(name, "jig", map[string]float64{"/github.com/iancmcc/jigsaw-extras": 10},
+				"/github.com/iancmcc/jigsaw-extras", "/github.com/iancmcc/jig")[0]).To(Equal("/github.com/iancmcc/jig"), name)
+		}
+	})
+
+	It("should return nothing when nothing matches", func() {
+		Expect(rank("fuzzy", "xyz", map[string]float64{"/github.com/iancmcc/jig": 1}, "/github.com/iancmcc/jig")).To(BeEmpty())
+	})
+
+})
@@ -0,0 +1,105 @@
+package match
+
+import "sort"
+
+// NewTokensMatcher returns a matcher for several tokens at once, each matched
+// by the matcher with a name, which only matches paths all of them match
+func NewTokensMatcher(name string, tokens []string) (Matcher, error) {
+	switch len(tokens) {
+	case 0:
+		return NewMatcher(name, "")
+	case 1:
+		return NewMatcher(name, tokens[0])
+	}
+	matchers := make([]Matcher, len(tokens))
+	for i, token := range tokens {
+		m, err := NewMatcher(name, token)
+		if err != nil {
+			return nil, err
+		}
+		matchers[i] = m
+	}
+	return All(matchers...), nil
+}
+
+// All returns a matcher that only matches what every one of matchers
+// matches. If they're all Scorers, so is it, scoring each match with the sum
+// of its scores; otherwise matches are in the order of the first matcher.
+func All(matchers ...Matcher) Matcher {
+	all := &allMatcher{matchers}
+	for _, m := range matchers {
+		if _, ok := m.(Scorer); !ok {
+			return all
+		}
+	}
+	return &scoredAllMatcher{all}
+}
+
+type allMatcher struct {
+	matchers []Matcher
+}
+
+// Add satisfies the Matcher interface
+func (m *allMatcher) Add(s string) {
+	for _, matcher := range m.matchers {
+		matcher.Add(s)
+	}
+}
+
+// Match satisfies the Matcher interface
+func (m *allMatcher) Match() []string {
+	if len(m.matchers) == 0 {
+		return []string{}
+	}
+	counts := map[string]int{}
+	for _, matcher := range m.matchers[1:] {
+		for _, value := range matcher.Match() {
+			counts[value]++
+		}
+	}
+	matched := []string{}
+	for _, value := range m.matchers[0].Match() {
+		if counts[value] == len(m.matchers)-1 {
+			matched = append(matched, value)
+		}
+	}
+	return matched
+}
+
+type scoredAllMatcher struct {
+	*allMatcher
+}
+
+// Match satisfies the Matcher interface
+func (m *scoredAllMatcher) Match() []string {
+	results := m.Results()
+	matched := make([]string, len(results))
+	for i, r := range results {
+		matched[i] = r.Value
+	}
+	return matched
+}
+
+// Results satisfies the Scorer interface
+func (m *scoredAllMatcher) Results() []Result {
+	if len(m.matchers) == 0 {
+		return []Result{}
+	}
+	counts := map[string]int{}
+	scores := map[string]float64{}
+	for _, matcher := range m.matchers[1:] {
+		for _, r := range matcher.(Scorer).Results() {
+			counts[r.Value]++
+			scores[r.Value] += r.Score
+		}
+	}
+	results := []Result{}
+	for _, r := range m.matchers[0].(Scorer).Results() {
+		if counts[r.Value] == len(m.matchers)-1 {
+			results = append(results, Result{r.Value, r.Score + scores[r.Value]})
+		}
+	}
+	// Stable, so equal scores keep the order of the first matcher
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	return results
+}
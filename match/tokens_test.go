@@ -0,0 +1,55 @@
+package match_test
+
+import (
+	. "github.com/iancmcc/jig/match"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewTokensMatcher", func() {
+
+	paths := []string{
+		"/github.com/zenoss/zenoss-core",
+		"/github.com/zenoss/zenpacks",
+		"/github.com/control-center/serviced",
+		"/github.com/iancmcc/core",
+	}
+
+	tokens := func(name string, tokens ...string) []string {
+		m, err := NewTokensMatcher(name, tokens)
+		Expect(err).To(BeNil())
+		for _, p := range paths {
+			m.Add(p)
+		}
+		return m.Match()
+	}
+
+	It("should only match paths every token matches", func() {
+		Expect(tokens("fuzzy", "zen", "core")).To(Equal([]string{"/github.com/zenoss/zenoss-core"}))
+		Expect(tokens("substring", "zen", "core")).To(Equal([]string{"/github.com/zenoss/zenoss-core"}))
+		Expect(tokens("fuzzy", "zen", "nope")).To(BeEmpty())
+	})
+
+	It("should match a single token like the matcher alone", func() {
+		m, err := NewTokensMatcher("fuzzy", []string{"core"})
+		Expect(err).To(BeNil())
+		Expect(m).To(BeAssignableToTypeOf(&FuzzyPathMatcher{}))
+	})
+
+	It("should score matches with all their tokens", func() {
+		m, err := NewTokensMatcher("fuzzy", []string{"zen", "pk"})
+		Expect(err).To(BeNil())
+		m.Add(paths[1])
+		results := m.(Scorer).Results()
+		zen, _ := FuzzyScore("zen", paths[1])
+		pk, _ := FuzzyScore("pk", paths[1])
+		Expect(results).To(Equal([]Result{{paths[1], float64(zen + pk)}}))
+	})
+
+	It("should refuse a matcher it doesn't know", func() {
+		_, err := NewTokensMatcher("nope", []string{"zen", "core"})
+		Expect(err).NotTo(BeNil())
+	})
+
+})
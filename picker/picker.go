@@ -0,0 +1,225 @@
+package picker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/iancmcc/jig/match"
+)
+
+// ErrCancelled is returned when the picker is closed without choosing
+var ErrCancelled = errors.New("nothing picked")
+
+// Picker lets someone choose one of many paths by typing part of it, showing
+// the best matches as they type
+type Picker struct {
+	// Paths are the candidates to choose from
+	Paths []string
+	// Matcher creates the matcher for a query
+	Matcher func(query string) match.Matcher
+	// Rank, if set, orders the matches of a matcher instead of its Match
+	Rank func(m match.Matcher) []string
+	// Describe, if set, returns what to show beside a path, like its branch
+	Describe func(path string) string
+	// Updates, if set, redraws the matches each time it receives, for when
+	// what Describe returns has changed
+	Updates <-chan struct{}
+	// Query is what's typed to start with
+	Query string
+	// Height is how many matches are shown at once
+	Height int
+	// Width is how wide the terminal is, if known
+	Width int
+
+	query    []rune
+	matches  []string
+	selected int
+	drawn    bool
+}
+
+// Run shows the picker on out, reading keys from in, and returns the path
+// chosen. in should be a terminal in raw mode.
+func (p *Picker) Run(in io.Reader, out io.Writer) (string, error) {
+	if p.Height <= 0 {
+		p.Height = 10
+	}
+	p.query = []rune(p.Query)
+	p.filter()
+	keys := make(chan []byte)
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := in.Read(buf)
+			if n > 0 {
+				select {
+				case keys <- append([]byte{}, buf[:n]...):
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	defer p.clear(out)
+	for {
+		p.draw(out)
+		select {
+		case key := <-keys:
+			if chosen, done, err := p.handle(key); done {
+				return chosen, err
+			}
+		case <-p.Updates:
+		case <-errs:
+			return "", ErrCancelled
+		}
+	}
+}
+
+// filter matches the candidates against the query
+func (p *Picker) filter() {
+	p.selected = 0
+	if len(p.query) == 0 {
+		p.matches = p.Paths
+		return
+	}
+	m := p.Matcher(string(p.query))
+	for _, path := range p.Paths {
+		m.Add(path)
+	}
+	if p.Rank != nil {
+		p.matches = p.Rank(m)
+	} else {
+		p.matches = m.Match()
+	}
+}
+
+// handle acts on a key, or several if they were typed quickly. It reports
+// whether the picker is done, and with what.
+func (p *Picker) handle(key []byte) (string, bool, error) {
+	for len(key) > 0 {
+		// Arrow keys arrive as escape sequences; an escape on its own cancels
+		if key[0] == 0x1b {
+			if len(key) >= 3 && (key[1] == '[' || key[1] == 'O') {
+				switch key[2] {
+				case 'A':
+					p.move(-1)
+				case 'B':
+					p.move(1)
+				}
+				key = key[3:]
+				continue
+			}
+			return "", true, ErrCancelled
+		}
+		r, size := utf8.DecodeRune(key)
+		key = key[size:]
+		switch r {
+		case '\r', '\n':
+			if len(p.matches) == 0 {
+				continue
+			}
+			return p.matches[p.selected], true, nil
+		case 0x03, 0x07: // Ctrl-C, Ctrl-G
+			return "", true, ErrCancelled
+		case 0x10, 0x0b: // Ctrl-P, Ctrl-K
+			p.move(-1)
+		case 0x0e: // Ctrl-N
+			p.move(1)
+		case 0x7f, 0x08: // Backspace
+			if len(p.query) > 0 {
+				p.query = p.query[:len(p.query)-1]
+				p.filter()
+			}
+		case 0x15: // Ctrl-U
+			p.query = p.query[:0]
+			p.filter()
+		case 0x17: // Ctrl-W
+			end := len(p.query)
+			for end > 0 && p.query[end-1] == ' ' {
+				end--
+			}
+			for end > 0 && p.query[end-1] != ' ' && p.query[end-1] != '/' {
+				end--
+			}
+			p.query = p.query[:end]
+			p.filter()
+		default:
+			if r != utf8.RuneError && unicode.IsPrint(r) {
+				p.query = append(p.query, r)
+				p.filter()
+			}
+		}
+	}
+	return "", false, nil
+}
+
+// move moves the selection, stopping at either end
+func (p *Picker) move(by int) {
+	p.selected += by
+	if p.selected >= len(p.matches) {
+		p.selected = len(p.matches) - 1
+	}
+	if p.selected < 0 {
+		p.selected = 0
+	}
+}
+
+// lines returns what's shown below the query: a count, then the matches
+// around the selection
+func (p *Picker) lines() []string {
+	lines := []string{fmt.Sprintf("  %d/%d", len(p.matches), len(p.Paths))}
+	first := 0
+	if p.selected >= p.Height {
+		first = p.selected - p.Height + 1
+	}
+	for i := first; i < len(p.matches) && i < first+p.Height; i++ {
+		line := p.matches[i]
+		if p.Describe != nil {
+			if detail := p.Describe(line); detail != "" {
+				line += "  " + detail
+			}
+		}
+		if p.Width > 3 && utf8.RuneCountInString(line) > p.Width-3 {
+			line = string([]rune(line)[:p.Width-3])
+		}
+		if i == p.selected {
+			line = "\x1b[7m> " + line + "\x1b[0m"
+		} else {
+			line = "  " + line
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// draw redraws the picker below the cursor, leaving the cursor after the
+// query
+func (p *Picker) draw(out io.Writer) {
+	var b strings.Builder
+	prompt := "> " + string(p.query)
+	b.WriteString("\r\x1b[J" + prompt)
+	lines := p.lines()
+	for _, line := range lines {
+		b.WriteString("\r\n" + line)
+	}
+	fmt.Fprintf(&b, "\x1b[%dA\r\x1b[%dC", len(lines), utf8.RuneCountInString(prompt))
+	io.WriteString(out, b.String())
+	p.drawn = true
+}
+
+// clear removes the picker from the screen
+func (p *Picker) clear(out io.Writer) {
+	if p.drawn {
+		io.WriteString(out, "\r\x1b[J")
+	}
+}
@@ -0,0 +1,74 @@
+package picker_test
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/iancmcc/jig/match"
+	. "github.com/iancmcc/jig/picker"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Picker", func() {
+
+	var (
+		out    bytes.Buffer
+		picker *Picker
+	)
+
+	BeforeEach(func() {
+		out.Reset()
+		picker = &Picker{
+			Paths: []string{
+				"github.com/iancmcc/jig",
+				"github.com/iancmcc/jigsaw",
+				"github.com/control-center/serviced",
+			},
+			Matcher: func(query string) match.Matcher { return match.DefaultMatcher(query) },
+		}
+	})
+
+	It("should pick the best match for what's typed", func() {
+		Expect(picker.Run(strings.NewReader("srv\r"), &out)).To(Equal("github.com/control-center/serviced"))
+	})
+
+	It("should start from the query given", func() {
+		picker.Query = "jigs"
+		Expect(picker.Run(strings.NewReader("\r"), &out)).To(Equal("github.com/iancmcc/jigsaw"))
+	})
+
+	It("should move the selection with the arrow keys", func() {
+		Expect(picker.Run(strings.NewReader("jig\x1b[B\r"), &out)).To(Equal("github.com/iancmcc/jigsaw"))
+		Expect(picker.Run(strings.NewReader("jig\x1b[B\x1b[B\x1b[A\x1b[A\r"), &out)).To(Equal("github.com/iancmcc/jig"))
+	})
+
+	It("should edit the query", func() {
+		Expect(picker.Run(strings.NewReader("jigxx\x7f\x7f\r"), &out)).To(Equal("github.com/iancmcc/jig"))
+		Expect(picker.Run(strings.NewReader("nothing\x15srv\r"), &out)).To(Equal("github.com/control-center/serviced"))
+	})
+
+	It("should ignore enter when nothing matches", func() {
+		_, err := picker.Run(strings.NewReader("zzz\r"), &out)
+		Expect(err).To(Equal(ErrCancelled))
+	})
+
+	It("should cancel on escape", func() {
+		_, err := picker.Run(strings.NewReader("jig\x1b"), &out)
+		Expect(err).To(Equal(ErrCancelled))
+	})
+
+	It("should show what Describe says about each match", func() {
+		picker.Describe = func(path string) string {
+			if path == "github.com/iancmcc/jigsaw" {
+				return "develop*"
+			}
+			return ""
+		}
+		picker.Run(strings.NewReader("jig\r"), &out)
+		Expect(out.String()).To(ContainSubstring("github.com/iancmcc/jigsaw  develop*"))
+		Expect(out.String()).To(ContainSubstring("  3/3"))
+	})
+
+})
@@ -0,0 +1,62 @@
+package picker
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// TTY is the controlling terminal, which the picker uses so that stdout is
+// left for the path picked
+type TTY struct {
+	*os.File
+	saved string
+}
+
+// OpenTTY opens the controlling terminal and puts it in raw mode
+func OpenTTY() (*TTY, error) {
+	f, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	t := &TTY{File: f}
+	saved, err := t.stty("-g")
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	t.saved = strings.TrimSpace(saved)
+	if _, err := t.stty("raw", "-echo"); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// Width returns the width of the terminal, or 0 if it isn't known
+func (t *TTY) Width() int {
+	size, err := t.stty("size")
+	if err != nil {
+		return 0
+	}
+	var rows, cols int
+	if _, err := fmt.Sscan(size, &rows, &cols); err != nil {
+		return 0
+	}
+	return cols
+}
+
+// Close restores the terminal as it was and closes it
+func (t *TTY) Close() error {
+	t.stty(t.saved)
+	return t.File.Close()
+}
+
+// stty runs stty on the terminal
+func (t *TTY) stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = t.File
+	out, err := cmd.Output()
+	return string(out), err
+}
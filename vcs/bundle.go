@@ -0,0 +1,163 @@
+package vcs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+)
+
+// ErrEmptyBundle is returned when a bundle would carry no history, because
+// everything the repository's refs point at is already in the commits to be
+// left out
+var ErrEmptyBundle = errors.New("nothing to bundle")
+
+// bundleRefs are the refs a bundle carries: local branches, tags and the
+// remote-tracking branches of origin
+var bundleRefs = []string{"refs/heads", "refs/tags", "refs/remotes/origin"}
+
+// Refs lists the refs a bundle of the repository would carry, by name
+func (g *gitVCS) Refs(r *config.Repo, dir string) (map[string]string, error) {
+	args := append([]string{"for-each-ref", "--format=%(objectname) %(refname)"}, bundleRefs...)
+	out, err := g.runNoProgress(r.Repo, dir, args...)
+	if err != nil {
+		return nil, err
+	}
+	refs := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Fields(line)
+		// origin/HEAD is a symbolic ref to a branch already listed
+		if len(fields) != 2 || fields[1] == "refs/remotes/origin/HEAD" {
+			continue
+		}
+		refs[fields[1]] = fields[0]
+	}
+	return refs, nil
+}
+
+// CreateBundle writes a git bundle of the repository to path. History
+// reachable from the commits in since is left out, so restoring the bundle
+// needs a repository that already has them. If that leaves nothing, as when
+// the refs have only moved to old commits or been deleted, ErrEmptyBundle is
+// returned and no bundle is written.
+func (g *gitVCS) CreateBundle(r *config.Repo, dir, path string, since []string) (<-chan Progress, error) {
+	revs := []string{"--branches", "--tags", "--remotes=origin"}
+	for _, sha := range since {
+		// Commits the repository has since lost can't be left out
+		if _, err := g.runNoProgress(r.Repo, dir, "cat-file", "-e", sha+"^{commit}"); err == nil {
+			revs = append(revs, "^"+sha)
+		}
+	}
+	if len(revs) > 3 {
+		// git refuses to write a bundle without any objects in it
+		count, err := g.runNoProgress(r.Repo, dir, append([]string{"rev-list", "--count", "--objects"}, revs...)...)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(string(count)) == "0" {
+			return nil, ErrEmptyBundle
+		}
+	}
+	if err := prepareDir(path); err != nil {
+		return nil, err
+	}
+	return g.run(r.Repo, dir, false, true, "bundle", append([]string{"create", "--progress", path}, revs...)...), nil
+}
+
+// RestoreBundle clones the repository into dir from a bundle, or updates it
+// from the bundle if it already exists, leaving origin pointing at the URL
+// the repository is normally fetched from. Without a bundle, the repository
+// must already exist and have the history refs point at; its tags and
+// remote-tracking branches are set from refs, as fetching a bundle would.
+func (g *gitVCS) RestoreBundle(r *config.Repo, dir, path string, refs map[string]string) (<-chan Progress, error) {
+	log := logrus.WithFields(logrus.Fields{
+		"repo":   r.Repo,
+		"bundle": path,
+	})
+	_, err := os.Stat(dir)
+	exists := err == nil
+	if path == "" {
+		if !exists {
+			return nil, fmt.Errorf("%s has no bundle to clone from", r.Repo)
+		}
+		return g.updateRefs(r, dir, refs), nil
+	}
+	if !exists {
+		if err := prepareDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	out := make(chan Progress)
+	go func() {
+		defer close(out)
+		if exists {
+			log.Debug("Updating git repo from bundle")
+			for p := range g.run(r.Repo, dir, true, true, "fetch", path,
+				"+refs/remotes/origin/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*") {
+				out <- p
+			}
+			g.fastForward(r, dir, out)
+			return
+		}
+		log.Debug("Cloning git repo from bundle")
+		if _, err := g.runNoProgress(r.Repo, ".", "init", "--quiet", dir); err != nil {
+			out <- Failed(r.Repo, err)
+			return
+		}
+		if _, err := g.runNoProgress(r.Repo, dir, "remote", "add", "origin", g.URL(r)); err != nil {
+			out <- Failed(r.Repo, err)
+			os.RemoveAll(dir)
+			return
+		}
+		// The branch HEAD names is unborn, so may be fetched into
+		progress, errc := g.runWait(r.Repo, dir, true, true, "fetch", "--update-head-ok", path,
+			"+refs/heads/*:refs/heads/*", "+refs/remotes/origin/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*")
+		for p := range progress {
+			out <- p
+		}
+		if err := <-errc; err != nil {
+			out <- Failed(r.Repo, err)
+			os.RemoveAll(dir)
+			return
+		}
+		for _, b := range append([]string{r.Ref}, trackingBranches...) {
+			if g.hasRef(r, dir, "refs/heads/"+b) && g.hasRef(r, dir, "refs/remotes/origin/"+b) {
+				g.runNoProgress(r.Repo, dir, "branch", "--quiet", "--set-upstream-to=origin/"+b, b)
+			}
+		}
+		// Force the checkout, since the index starts out empty even if HEAD
+		// already names the ref
+		if err := runWithOutput(dir, "checkout", "--quiet", "-f", r.Ref); err != nil {
+			out <- Failed(r.Repo, err)
+		}
+	}()
+	return out, nil
+}
+
+// updateRefs points the tags and remote-tracking branches of the repository
+// in dir at the commits in refs, then fast-forwards the current branch
+func (g *gitVCS) updateRefs(r *config.Repo, dir string, refs map[string]string) <-chan Progress {
+	out := make(chan Progress)
+	go func() {
+		defer close(out)
+		logrus.WithField("repo", r.Repo).Debug("Updating git refs from bundle")
+		for ref, sha := range refs {
+			if !strings.HasPrefix(ref, "refs/tags/") && !strings.HasPrefix(ref, "refs/remotes/origin/") {
+				continue
+			}
+			if _, err := g.runNoProgress(r.Repo, dir, "cat-file", "-e", sha); err != nil {
+				out <- Failed(r.Repo, fmt.Errorf("%s is missing %s; restore the bundle it was made since first", ref, sha))
+				return
+			}
+			if err := runWithOutput(dir, "update-ref", ref, sha); err != nil {
+				out <- Failed(r.Repo, err)
+				return
+			}
+		}
+		g.fastForward(r, dir, out)
+	}()
+	return out
+}
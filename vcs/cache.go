@@ -0,0 +1,138 @@
+package vcs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/utils"
+)
+
+// ObjectCache is a directory of bare mirrors, laid out like a Jig root, that
+// clones borrow objects from instead of downloading full histories again
+type ObjectCache struct {
+	Dir string
+}
+
+// Mirror describes a bare mirror held in an ObjectCache
+type Mirror struct {
+	Repo    string
+	Path    string
+	Size    int64
+	Updated time.Time
+}
+
+// DefaultCacheDir returns the object cache location under the user's cache
+// directory
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "jig", "objects"), nil
+}
+
+// MirrorPath returns the path of the mirror for a repository URI
+func (c *ObjectCache) MirrorPath(uri string) (string, error) {
+	short, err := utils.RepoToPath(uri)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(c.Dir, short+".git"), nil
+}
+
+// Refresh creates the mirror for a repository URI by cloning url, or fetches
+// into it if it already exists
+func (c *ObjectCache) Refresh(uri, url string) (<-chan Progress, error) {
+	if Git.Offline {
+		return nil, ErrOffline
+	}
+	path, err := c.MirrorPath(uri)
+	if err != nil {
+		return nil, err
+	}
+	log := logrus.WithFields(logrus.Fields{
+		"repo":   uri,
+		"mirror": path,
+	})
+	if _, err := os.Stat(path); err == nil {
+		log.Debug("Refreshing cached mirror")
+		return Git.run(uri, path, true, true, "fetch", "--prune", "origin"), nil
+	}
+	if err := prepareDir(path); err != nil {
+		return nil, err
+	}
+	log.Debug("Creating cached mirror")
+	// Clone to a temporary name so an interrupted clone never leaves a
+	// half-populated mirror behind for later clones to reference
+	tmp := path + "~"
+	os.RemoveAll(tmp)
+	out := make(chan Progress)
+	go func() {
+		defer close(out)
+		progress, errc := Git.runWait(uri, ".", true, true, "clone", "--mirror", url, tmp)
+		for p := range progress {
+			out <- p
+		}
+		if err := <-errc; err != nil {
+			os.RemoveAll(tmp)
+			out <- Failed(uri, err)
+			return
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			log.WithError(err).Error("Unable to store cached mirror")
+			os.RemoveAll(tmp)
+			out <- Failed(uri, err)
+		}
+	}()
+	return out, nil
+}
+
+// Mirrors lists the mirrors currently held in the cache
+func (c *ObjectCache) Mirrors() ([]*Mirror, error) {
+	paths := []string{}
+	err := filepath.Walk(c.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() && strings.HasSuffix(path, ".git") {
+			paths = append(paths, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	mirrors := []*Mirror{}
+	for _, path := range paths {
+		rel, err := filepath.Rel(c.Dir, path)
+		if err != nil {
+			continue
+		}
+		m := &Mirror{
+			Repo: filepath.ToSlash(strings.TrimSuffix(rel, ".git")),
+			Path: path,
+		}
+		m.Size, _ = utils.DirSize(path)
+		// FETCH_HEAD is rewritten on every refresh; a fresh mirror has none
+		for _, name := range []string{"FETCH_HEAD", "HEAD"} {
+			if info, err := os.Stat(filepath.Join(path, name)); err == nil {
+				m.Updated = info.ModTime()
+				break
+			}
+		}
+		mirrors = append(mirrors, m)
+	}
+	return mirrors, nil
+}
+
+// Remove deletes a mirror from the cache
+func (c *ObjectCache) Remove(m *Mirror) error {
+	return os.RemoveAll(m.Path)
+}
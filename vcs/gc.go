@@ -0,0 +1,169 @@
+package vcs
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+	"github.com/iancmcc/jig/utils"
+)
+
+// GCOptions controls the housekeeping done by GC
+type GCOptions struct {
+	// DryRun reports what would be deleted without deleting anything
+	DryRun bool
+	// Fsck also verifies the integrity of the object database
+	Fsck bool
+}
+
+// GCResult reports the housekeeping done to a repository
+type GCResult struct {
+	Repo            string
+	PrunedRefs      []string
+	DeletedBranches []string
+	Reclaimed       int64
+	FsckErr         error
+	Err             error
+}
+
+// GC prunes stale remote-tracking refs, deletes local branches that are fully
+// merged into their upstream or the manifest ref, and repacks the repository
+// if git thinks it needs it
+func (g *gitVCS) GC(r *config.Repo, dir string, opts GCOptions) *GCResult {
+	short, err := utils.RepoToPath(r.Repo)
+	if err != nil {
+		short = r.Repo
+	}
+	result := &GCResult{Repo: short}
+	log := logrus.WithField("repo", short)
+	gitdir := filepath.Join(dir, ".git")
+	before, _ := utils.DirSize(gitdir)
+
+	if result.PrunedRefs, err = g.pruneRemotes(r, dir, opts.DryRun); err != nil {
+		result.Err = err
+		return result
+	}
+	if result.DeletedBranches, err = g.deleteMerged(r, dir, opts.DryRun); err != nil {
+		result.Err = err
+		return result
+	}
+	if opts.DryRun {
+		return result
+	}
+	log.Debug("Running git gc")
+	if _, err := g.runNoProgress(r.Repo, dir, "gc", "--auto", "--quiet"); err != nil {
+		result.Err = err
+		return result
+	}
+	if opts.Fsck {
+		log.Debug("Running git fsck")
+		if data, err := rawGitRun(dir, "fsck", "--no-progress"); err != nil {
+			if msg := bytes.TrimSpace(data); len(msg) > 0 {
+				err = errors.New(string(msg))
+			}
+			result.FsckErr = err
+		}
+	}
+	after, _ := utils.DirSize(gitdir)
+	result.Reclaimed = before - after
+	return result
+}
+
+// pruneRemotes removes remote-tracking refs whose branch no longer exists on
+// the remote
+func (g *gitVCS) pruneRemotes(r *config.Repo, dir string, dryRun bool) ([]string, error) {
+	remotes, err := g.runNoProgress(r.Repo, dir, "remote")
+	if err != nil {
+		return nil, err
+	}
+	pruned := []string{}
+	for _, remote := range strings.Fields(string(remotes)) {
+		args := []string{"remote", "prune", remote}
+		if dryRun {
+			args = append(args, "--dry-run")
+		}
+		data, err := g.runNoProgress(r.Repo, dir, args...)
+		if err != nil {
+			return nil, err
+		}
+		// Lines look like " * [pruned] origin/topic"
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if !strings.HasPrefix(line, "* [") {
+				continue
+			}
+			if i := strings.Index(line, "] "); i >= 0 {
+				pruned = append(pruned, line[i+2:])
+			}
+		}
+	}
+	return pruned, nil
+}
+
+// deleteMerged deletes local branches fully merged into their upstream or
+// into the manifest ref. Branches checked out here or in any other worktree,
+// the manifest ref and the branches jig sets up at clone time are never
+// deleted.
+func (g *gitVCS) deleteMerged(r *config.Repo, dir string, dryRun bool) ([]string, error) {
+	current, _, err := branch(dir)
+	if err != nil {
+		return nil, err
+	}
+	keep := map[string]struct{}{
+		string(current): {},
+		r.Ref:           {},
+	}
+	for _, b := range trackingBranches {
+		keep[b] = struct{}{}
+	}
+	checkedOut, err := g.worktreeBranches(r, dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range checkedOut {
+		keep[b] = struct{}{}
+	}
+	base := "origin/" + r.Ref
+	if !g.hasRef(r, dir, "refs/remotes/"+base) {
+		base = r.Ref
+	}
+	data, err := g.runNoProgress(r.Repo, dir, "for-each-ref", "--format=%(refname:short) %(upstream:short)", "refs/heads")
+	if err != nil {
+		return nil, err
+	}
+	deleted := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		name := fields[0]
+		if _, ok := keep[name]; ok {
+			continue
+		}
+		merged := false
+		for _, target := range append(fields[1:], base) {
+			if _, err := g.runNoProgress(r.Repo, dir, "merge-base", "--is-ancestor", name, target); err == nil {
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			continue
+		}
+		if !dryRun {
+			if _, err := g.runNoProgress(r.Repo, dir, "branch", "-D", name); err != nil {
+				logrus.WithError(err).WithFields(logrus.Fields{
+					"repo":   r.Repo,
+					"branch": name,
+				}).Warn("Unable to delete merged branch")
+				continue
+			}
+		}
+		deleted = append(deleted, name)
+	}
+	return deleted, nil
+}
@@ -3,6 +3,7 @@ package vcs
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
@@ -21,13 +22,30 @@ var (
 	// Git is the singleton driver
 	Git = &gitVCS{}
 
-	absolute = regexp.MustCompile(`(remote: )?([\w\s]+):\s+()(\d+)()(.*)`)
-	relative = regexp.MustCompile(`(remote: )?([\w\s]+):\s+(\d+)% \((\d+)/(\d+)\)(.*)`)
+	absolute    = regexp.MustCompile(`(remote: )?([\w\s]+):\s+()(\d+)()(.*)`)
+	relative    = regexp.MustCompile(`(remote: )?([\w\s]+):\s+(\d+)% \((\d+)/(\d+)\)(.*)`)
+	transferred = regexp.MustCompile(`([\d.]+) (bytes|KiB|MiB|GiB|TiB)`)
+	byteUnits   = map[string]float64{
+		"bytes": 1,
+		"KiB":   1 << 10,
+		"MiB":   1 << 20,
+		"GiB":   1 << 30,
+		"TiB":   1 << 40,
+	}
 
 	mu        = &sync.Mutex{}
 	repolocks = map[string]*sync.Mutex{}
+
+	// trackingBranches are set up to track origin in every full clone
+	trackingBranches = []string{"develop", "master"}
+
+	gitVersionPattern = regexp.MustCompile(`(\d+)\.(\d+)`)
 )
 
+// refetchVersion is the first git able to fetch --refetch, which turning a
+// partial clone into a full one needs
+var refetchVersion = [2]int{2, 36}
+
 func getRepoLock(dir string) (mutex *sync.Mutex) {
 	var ok bool
 	mu.Lock()
@@ -41,15 +59,53 @@ func getRepoLock(dir string) (mutex *sync.Mutex) {
 
 // GitVCS is a git driver
 type gitVCS struct {
+	// Cache, if set, is used as a source of objects for clones and is
+	// refreshed on pulls
+	Cache *ObjectCache
+	// Rewrite changes the URLs repositories are cloned from
+	Rewrite config.RewriteSettings
+	// Offline, if set, keeps every operation away from the network
+	Offline bool
+}
+
+// URL returns the URL a repository is cloned from, after rewrite rules
+func (g *gitVCS) URL(r *config.Repo) string {
+	return g.Rewrite.Apply(r.Repo)
+}
+
+// refreshCache updates the cached mirror for a repository, if a cache is in
+// use, and returns the path of the mirror
+func (g *gitVCS) refreshCache(r *config.Repo, out chan<- Progress) string {
+	if g.Cache == nil {
+		return ""
+	}
+	refreshchan, err := g.Cache.Refresh(r.Repo, g.URL(r))
+	if err != nil {
+		logrus.WithError(err).WithField("repo", r.Repo).Warn("Unable to refresh cached mirror")
+		return ""
+	}
+	for p := range refreshchan {
+		// Clones go ahead without the mirror if it can't be refreshed
+		if p.Err != nil {
+			logrus.WithError(p.Err).WithField("repo", r.Repo).Warn("Unable to refresh cached mirror")
+			continue
+		}
+		out <- p
+	}
+	mirror, _ := g.Cache.MirrorPath(r.Repo)
+	return mirror
 }
 
-func parseProgress(repo string, r io.Reader) (<-chan Progress, <-chan bool) {
+// parseProgress reads the progress git reports on stderr. Once r is drained,
+// the second channel receives the last error git complained of, if any.
+func parseProgress(repo string, r io.Reader) (<-chan Progress, <-chan string) {
 	out := make(chan Progress)
 	scanner := bufio.NewScanner(r)
 
 	scanner.Split(split)
-	done := make(chan bool)
+	done := make(chan string, 1)
 	go func() {
+		var complaint string
 		seen := map[string]struct{}{}
 		for scanner.Scan() {
 			var (
@@ -62,6 +118,9 @@ func parseProgress(repo string, r io.Reader) (<-chan Progress, <-chan bool) {
 				match = absolute.FindStringSubmatch(text)
 			}
 			if len(match) == 0 {
+				if strings.HasPrefix(text, "fatal: ") || strings.HasPrefix(text, "error: ") {
+					complaint = text
+				}
 				continue
 			}
 			if strings.HasSuffix(text, "done.") {
@@ -78,21 +137,44 @@ func parseProgress(repo string, r io.Reader) (<-chan Progress, <-chan bool) {
 				begin = true
 			}
 			prog := Progress{
-				repo,
-				begin,
-				end,
-				op,
-				cur,
-				max,
+				Repo:    repo,
+				IsBegin: begin,
+				IsEnd:   end,
+				Message: op,
+				Phase:   ParsePhase(op),
+				Current: cur,
+				Total:   max,
+				Bytes:   parseBytes(match[6]),
 			}
 			out <- prog
 		}
 		close(out)
+		done <- complaint
 		close(done)
 	}()
 	return out, done
 }
 
+// ParseProgress reads the progress git reports on stderr
+func ParseProgress(repo string, r io.Reader) <-chan Progress {
+	out, _ := parseProgress(repo, r)
+	return out
+}
+
+// parseBytes reads the amount of data transferred from the tail of a
+// progress line, e.g. ", 6.33 MiB | 12.62 MiB/s"
+func parseBytes(s string) int64 {
+	match := transferred.FindStringSubmatch(s)
+	if match == nil {
+		return 0
+	}
+	n, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0
+	}
+	return int64(n * byteUnits[match[2]])
+}
+
 func rawGitRun(wd string, args ...string) ([]byte, error) {
 	if wd != "." {
 		lock := getRepoLock(wd)
@@ -104,7 +186,30 @@ func rawGitRun(wd string, args ...string) ([]byte, error) {
 	return command.CombinedOutput()
 }
 
+// run runs a git command, returning its progress. If logerror is set and the
+// command fails, the progress ends with the error, so the channel must be
+// drained.
 func (g *gitVCS) run(repo, wd string, progress, logerror bool, cmd string, args ...string) <-chan Progress {
+	result, errc := g.runWait(repo, wd, progress, logerror, cmd, args...)
+	if !logerror {
+		return result
+	}
+	out := make(chan Progress)
+	go func() {
+		defer close(out)
+		for p := range result {
+			out <- p
+		}
+		if err := <-errc; err != nil {
+			out <- Failed(repo, err)
+		}
+	}()
+	return out
+}
+
+// runWait is run, but also returns a channel that receives the result of the
+// command once its progress stream has been drained
+func (g *gitVCS) runWait(repo, wd string, progress, logerror bool, cmd string, args ...string) (<-chan Progress, <-chan error) {
 	var lock *sync.Mutex
 	if wd != "." {
 		lock = getRepoLock(wd)
@@ -130,18 +235,35 @@ func (g *gitVCS) run(repo, wd string, progress, logerror bool, cmd string, args
 	command := exec.Command("git", args...)
 	command.Dir = wd
 	progout, _ := command.StderrPipe()
-	command.Start()
+	errc := make(chan error, 1)
+	if err := command.Start(); err != nil {
+		if wd != "." {
+			lock.Unlock()
+		}
+		if logerror {
+			log.WithError(err).Error("Problem running git command")
+		}
+		result := make(chan Progress)
+		close(result)
+		errc <- err
+		return result, errc
+	}
 	result, done := parseProgress(repo, progout)
 	go func() {
 		if wd != "." {
 			defer lock.Unlock()
 		}
-		<-done
-		if err := command.Wait(); err != nil && logerror {
-			log.Error("Problem running git command")
+		complaint := <-done
+		err := command.Wait()
+		if err != nil && complaint != "" {
+			err = fmt.Errorf("%s: %s", strcmd, complaint)
 		}
+		if err != nil && logerror {
+			log.WithError(err).Error("Problem running git command")
+		}
+		errc <- err
 	}()
-	return result
+	return result, errc
 }
 
 func (g *gitVCS) runNoProgress(repo, wd string, args ...string) ([]byte, error) {
@@ -174,8 +296,27 @@ func prepareDir(dir string) error {
 	return os.MkdirAll(filepath.Dir(dir), os.ModeDir|0775)
 }
 
+// cloneArgs builds the git clone arguments that limit how much of a
+// repository is fetched
+func cloneArgs(r *config.Repo, opts CloneOptions) []string {
+	args := []string{}
+	if opts.Shallow {
+		args = append(args, "--depth", "1", "--no-single-branch", "-b", r.Ref)
+	}
+	if filter := opts.filter(r); filter != "" {
+		args = append(args, "--filter="+filter)
+	}
+	if len(opts.sparse(r)) > 0 {
+		args = append(args, "--sparse")
+	}
+	return args
+}
+
 // Clone satisfies the VCS interface
-func (g *gitVCS) Clone(r *config.Repo, dir string, attemptShallow bool) (<-chan Progress, error) {
+func (g *gitVCS) Clone(r *config.Repo, dir string, opts CloneOptions) (<-chan Progress, error) {
+	if g.Offline {
+		return nil, fmt.Errorf("unable to clone: %w", ErrOffline)
+	}
 	log := logrus.WithFields(logrus.Fields{
 		"repo": r.Repo,
 		"ref":  r.Ref,
@@ -188,25 +329,159 @@ func (g *gitVCS) Clone(r *config.Repo, dir string, attemptShallow bool) (<-chan
 	out := make(chan Progress)
 	go func() {
 		defer close(out)
-		if attemptShallow {
-			for p := range g.run(r.Repo, ".", true, true, "clone", "--depth", "1", "-b", r.Ref, r.Repo, dir) {
+		var reference []string
+		if mirror := g.refreshCache(r, out); mirror != "" {
+			reference = []string{"--reference-if-able", mirror, "--dissociate"}
+		}
+		args := append(reference, cloneArgs(r, opts)...)
+		args = append(args, g.URL(r), dir)
+		progress, errc := g.runWait(r.Repo, ".", true, true, "clone", args...)
+		for p := range progress {
+			out <- p
+		}
+		if err := <-errc; err != nil {
+			out <- Failed(r.Repo, err)
+			return
+		}
+		if sparse := opts.sparse(r); len(sparse) > 0 {
+			args := append([]string{"set", "--cone"}, sparse...)
+			for p := range g.run(r.Repo, dir, false, true, "sparse-checkout", args...) {
+				out <- p
+			}
+		}
+		if !opts.Shallow {
+			for p := range g.run(r.Repo, dir, true, true, "fetch", "--all") {
+				out <- p
+			}
+		}
+		for _, b := range trackingBranches {
+			g.run(r.Repo, dir, false, false, "branch", "--track", b, "origin/"+b)
+		}
+		if !opts.Shallow {
+			g.run(r.Repo, dir, false, false, "flow", "init", "-d")
+		}
+	}()
+	return out, nil
+}
+
+// Deepen satisfies the VCS interface
+func (g *gitVCS) Deepen(r *config.Repo, dir string, depth int, disableSparse bool) (<-chan Progress, error) {
+	if g.Offline {
+		return nil, ErrOffline
+	}
+	shape, err := g.Shape(r, dir)
+	if err != nil {
+		return nil, err
+	}
+	if depth <= 0 && shape.Partial {
+		major, minor, err := GitVersion()
+		if err != nil {
+			return nil, err
+		}
+		if major < refetchVersion[0] || major == refetchVersion[0] && minor < refetchVersion[1] {
+			return nil, fmt.Errorf("fetching what a partial clone left out needs git %d.%d or later, not %d.%d",
+				refetchVersion[0], refetchVersion[1], major, minor)
+		}
+	}
+	log := logrus.WithFields(logrus.Fields{
+		"repo":  r.Repo,
+		"depth": depth,
+	})
+	out := make(chan Progress)
+	go func() {
+		defer close(out)
+		if depth > 0 {
+			if !shape.Shallow {
+				log.Debug("Skipping deepen since repo is not shallow")
+				return
+			}
+			for p := range g.run(r.Repo, dir, true, true, "fetch", "--deepen", strconv.Itoa(depth)) {
 				out <- p
 			}
 			return
 		}
-		for p := range g.run(r.Repo, ".", true, true, "clone", r.Repo, dir) {
-			out <- p
+		if shape.Shallow {
+			log.Debug("Unshallowing git repo")
+			// Shallow clones made with -b only track a single branch
+			g.runNoProgress(r.Repo, dir, "remote", "set-branches", "origin", "*")
+			for p := range g.run(r.Repo, dir, true, true, "fetch", "--unshallow", "origin") {
+				out <- p
+			}
 		}
-		for p := range g.run(r.Repo, dir, true, true, "fetch", "--all") {
-			out <- p
+		if shape.Partial {
+			log.Debug("Fetching objects omitted by partial clone")
+			// The filter has to go for the refetch to get everything, but
+			// comes back if it fails, so the clone is left partial rather
+			// than half converted
+			filter, _ := g.runNoProgress(r.Repo, dir, "config", "--get", "remote.origin.partialclonefilter")
+			g.runNoProgress(r.Repo, dir, "config", "--unset", "remote.origin.partialclonefilter")
+			progress, errc := g.runWait(r.Repo, dir, true, true, "fetch", "--refetch", "origin")
+			for p := range progress {
+				out <- p
+			}
+			if err := <-errc; err != nil {
+				if len(filter) > 0 {
+					g.runNoProgress(r.Repo, dir, "config", "remote.origin.partialclonefilter", string(filter))
+				}
+				out <- Failed(r.Repo, err)
+			} else {
+				g.runNoProgress(r.Repo, dir, "config", "--unset", "remote.origin.promisor")
+			}
+		}
+		if shape.Sparse && disableSparse {
+			log.Debug("Disabling sparse checkout")
+			for p := range g.run(r.Repo, dir, false, true, "sparse-checkout", "disable") {
+				out <- p
+			}
 		}
-		g.run(r.Repo, dir, false, false, "branch", "--track", "develop", "origin/develop")
-		g.run(r.Repo, dir, false, false, "branch", "--track", "master", "origin/master")
-		g.run(r.Repo, dir, false, false, "flow", "init", "-d")
 	}()
 	return out, nil
 }
 
+// Shape reports how much of a repository is present locally
+func (g *gitVCS) Shape(r *config.Repo, dir string) (*Shape, error) {
+	shallow, err := g.runNoProgress(r.Repo, dir, "rev-parse", "--is-shallow-repository")
+	if err != nil {
+		return nil, err
+	}
+	shape := &Shape{
+		Shallow: string(shallow) == "true",
+	}
+	// git config exits non-zero when a key is unset
+	if promisor, err := g.runNoProgress(r.Repo, dir, "config", "--get", "remote.origin.promisor"); err == nil {
+		shape.Partial = string(promisor) == "true"
+	}
+	if sparse, err := g.runNoProgress(r.Repo, dir, "config", "--get", "core.sparseCheckout"); err == nil {
+		shape.Sparse = string(sparse) == "true"
+	}
+	return shape, nil
+}
+
+// GitVersion returns the major and minor version of git
+func GitVersion() (int, int, error) {
+	out, err := rawGitRun(".", "version")
+	if err != nil {
+		return 0, 0, err
+	}
+	major, minor, ok := ParseGitVersion(string(out))
+	if !ok {
+		return 0, 0, fmt.Errorf("unexpected git version %q", bytes.TrimSpace(out))
+	}
+	return major, minor, nil
+}
+
+// ParseGitVersion reads the major and minor version from what git version
+// prints, like "git version 2.39.3 (Apple Git-146)"
+func ParseGitVersion(s string) (int, int, bool) {
+	match := gitVersionPattern.FindStringSubmatch(s)
+	if match == nil {
+		return 0, 0, false
+	}
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+	return major, minor, true
+}
+
 func branch(dir string) ([]byte, bool, error) {
 	brnch, err := rawGitRun(dir, "rev-parse", "--abbrev-ref", "HEAD")
 	if err != nil {
@@ -236,10 +511,6 @@ func (g *gitVCS) Status(r *config.Repo, dir string) (*Status, error) {
 	if err != nil {
 		return nil, err
 	}
-	status, err := g.runNoProgress(r.Repo, dir, "status", "-z", "--porcelain")
-	if err != nil {
-		return nil, err
-	}
 	short, err := utils.RepoToPath(r.Repo)
 	if err != nil {
 		return nil, err
@@ -248,7 +519,28 @@ func (g *gitVCS) Status(r *config.Repo, dir string) (*Status, error) {
 		Branch:  strings.TrimSpace(string(branch)),
 		OrigRef: r.Ref,
 		Repo:    short,
+		URL:     g.URL(r),
 	}
+	if shape, err := g.Shape(r, dir); err == nil {
+		result.Shape = *shape
+	}
+	// Branches without an upstream are neither
+	result.Ahead, result.Behind, _ = g.AheadBehind(r, dir)
+	if err := g.Dirty(r, dir, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Dirty sets whether the working tree of a repository has staged, unstaged
+// or untracked changes in a status
+func (g *gitVCS) Dirty(r *config.Repo, dir string, result *Status) error {
+	// Not trimmed, as the first entry may start with a space
+	status, err := rawGitRun(dir, "status", "-z", "--porcelain")
+	if err != nil {
+		return err
+	}
+	result.Staged, result.Unstaged, result.Untracked = false, false, false
 	for _, s := range bytes.Split(status, []byte{'\x00'}) {
 		if len(s) == 0 {
 			continue
@@ -266,10 +558,31 @@ func (g *gitVCS) Status(r *config.Repo, dir string) (*Status, error) {
 			result.Staged = true
 		}
 	}
-	return result, nil
+	return nil
 }
 
-// Pull satisfies the VCS interface
+// Fetch satisfies the VCS interface
+func (g *gitVCS) Fetch(r *config.Repo, dir string) (<-chan Progress, error) {
+	if g.Offline {
+		return nil, ErrOffline
+	}
+	out := make(chan Progress)
+	go func() {
+		defer close(out)
+		g.fetch(r, dir, out)
+	}()
+	return out, nil
+}
+
+func (g *gitVCS) fetch(r *config.Repo, dir string, out chan<- Progress) {
+	g.refreshCache(r, out)
+	for p := range g.run(r.Repo, dir, true, true, "fetch", "--all") {
+		out <- p
+	}
+}
+
+// Pull satisfies the VCS interface. Offline, it fast-forwards to whatever
+// was fetched last instead.
 func (g *gitVCS) Pull(r *config.Repo, dir string) (<-chan Progress, error) {
 	_, isbranch, _ := g.Branch(r, dir)
 	log := logrus.WithFields(logrus.Fields{
@@ -278,13 +591,17 @@ func (g *gitVCS) Pull(r *config.Repo, dir string) (<-chan Progress, error) {
 	out := make(chan Progress)
 	go func() {
 		defer close(out)
-		for p := range g.run(r.Repo, dir, true, true, "fetch", "--all") {
-			out <- p
+		if !g.Offline {
+			g.fetch(r, dir, out)
 		}
 		if !isbranch {
 			log.Debug("Skipping pull since not on a branch")
 			return
 		}
+		if g.Offline {
+			g.fastForward(r, dir, out)
+			return
+		}
 		log.Debug("Pulling git repo")
 		defer log.Debug("Pulled git repo")
 		for p := range g.run(r.Repo, dir, true, true, "pull") {
@@ -294,12 +611,72 @@ func (g *gitVCS) Pull(r *config.Repo, dir string) (<-chan Progress, error) {
 	return out, nil
 }
 
+// fastForward brings the current branch up to date with what has already
+// been fetched for its upstream
+func (g *gitVCS) fastForward(r *config.Repo, dir string, out chan<- Progress) {
+	if _, isbranch, _ := g.Branch(r, dir); !isbranch {
+		return
+	}
+	if _, err := g.runNoProgress(r.Repo, dir, "rev-parse", "--verify", "--quiet", "@{upstream}"); err != nil {
+		logrus.WithField("repo", r.Repo).Debug("Skipping fast-forward since branch has no upstream")
+		return
+	}
+	for p := range g.run(r.Repo, dir, false, true, "merge", "--ff-only", "@{upstream}") {
+		out <- p
+	}
+}
+
+// HasLocalRef reports whether ref can be checked out without fetching it,
+// either because it names a commit, branch or tag in the repository, or
+// because there is a remote-tracking branch for it
+func (g *gitVCS) HasLocalRef(r *config.Repo, dir, ref string) bool {
+	if _, err := g.runNoProgress(r.Repo, dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err == nil {
+		return true
+	}
+	return g.hasRef(r, dir, "refs/remotes/origin/"+ref)
+}
+
+// Incoming counts the commits on the upstream of the current branch that
+// aren't on the branch yet
+func (g *gitVCS) Incoming(r *config.Repo, dir string) (int, error) {
+	count, err := g.runNoProgress(r.Repo, dir, "rev-list", "--count", "HEAD..@{upstream}")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(bytes.TrimSpace(count)))
+}
+
+// AheadBehind counts the commits on the current branch that aren't on its
+// upstream, and the commits on the upstream that aren't on the branch
+func (g *gitVCS) AheadBehind(r *config.Repo, dir string) (int, int, error) {
+	counts, err := g.runNoProgress(r.Repo, dir, "rev-list", "--left-right", "--count", "HEAD...@{upstream}")
+	if err != nil {
+		return 0, 0, err
+	}
+	fields := strings.Fields(string(counts))
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("unexpected rev-list output %q", counts)
+	}
+	ahead, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	behind, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return ahead, behind, nil
+}
+
 // Checkout satisfies the VCS interface
 func (g *gitVCS) Checkout(r *config.Repo, dir string) error {
 	br, _, _ := branch(dir)
 	if br != nil && string(br) == r.Ref {
 		return nil
 	}
+	if g.Offline && !g.HasLocalRef(r, dir, r.Ref) {
+		return fmt.Errorf("%s is %w", r.Ref, ErrNotLocal)
+	}
 	data, err := rawGitRun(dir, "checkout", r.Ref)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
@@ -307,30 +684,31 @@ func (g *gitVCS) Checkout(r *config.Repo, dir string) error {
 			"repo": r.Repo,
 			"ref":  r.Ref,
 		}).Error("Unable to checkout ref")
+		return fmt.Errorf("git checkout %s: %s", r.Ref, bytes.TrimSpace(data))
 	}
-	return err
+	return nil
 }
 
-// dropCR drops a terminal \r from the data.
+// SetOrigin points the origin remote of the repository in dir at the URL the
+// manifest URI rewrites to
+func (g *gitVCS) SetOrigin(r *config.Repo, dir string) error {
+	return runWithOutput(dir, "remote", "set-url", "origin", g.URL(r))
+}
+
+// dropCR drops a terminal \r from the data, and the erase-to-end-of-line
+// sequence git puts after progress relayed from the remote.
 func dropCR(data []byte) []byte {
-	if len(data) > 0 && data[len(data)-1] == '\r' {
-		data = data[0 : len(data)-1]
-	}
-	if len(data) > 0 && data[len(data)-3] == '' {
-		data = data[0 : len(data)-3]
-	}
-	return data
+	data = bytes.TrimSuffix(data, []byte("\r"))
+	return bytes.TrimSuffix(data, []byte("\x1b[K"))
 }
 
 func split(data []byte, atEOF bool) (advance int, token []byte, err error) {
 	if atEOF && len(data) == 0 {
 		return 0, nil, nil
 	}
-	if i := bytes.IndexByte(data, ''); i >= 0 {
-		// We have a full newline-terminated line.
-		return i + 1, dropCR(data[0:i]), nil
-	}
-	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+	// git ends progress updates with \r and the last update of a phase with
+	// \n, so a line ends at whichever comes first
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
 		// We have a full newline-terminated line.
 		return i + 1, dropCR(data[0:i]), nil
 	}
@@ -0,0 +1,173 @@
+package vcs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/iancmcc/jig/config"
+	. "github.com/iancmcc/jig/vcs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseGitVersion", func() {
+
+	It("reads the major and minor version", func() {
+		for out, expected := range map[string][2]int{
+			"git version 2.39.5\n":                 {2, 39},
+			"git version 2.39.3 (Apple Git-146)\n": {2, 39},
+			"git version 2.37.1.windows.1\n":       {2, 37},
+			"git version 1.8.3.1\n":                {1, 8},
+		} {
+			major, minor, ok := ParseGitVersion(out)
+			Ω(ok).Should(BeTrue())
+			Ω([2]int{major, minor}).Should(Equal(expected))
+		}
+	})
+
+	It("rejects anything else", func() {
+		_, _, ok := ParseGitVersion("git: command not found")
+		Ω(ok).Should(BeFalse())
+	})
+
+})
+
+var _ = Describe("Git", func() {
+
+	var (
+		tempdir string
+		repo    = &config.Repo{Repo: "https://example.com/owner/repo"}
+		git     = func(args ...string) {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = tempdir
+			out, err := cmd.CombinedOutput()
+			Expect(err).To(BeNil(), string(out))
+		}
+	)
+
+	BeforeEach(func() {
+		if _, err := exec.LookPath("git"); err != nil {
+			Skip("git isn't installed")
+		}
+		td, err := ioutil.TempDir("", "jig-")
+		Expect(err).To(BeNil())
+		tempdir = td
+		git("init", "-q")
+		git("-c", "user.name=jig", "-c", "user.email=jig@example.com", "commit", "-q", "--allow-empty", "-m", "initial")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempdir)
+	})
+
+	It("sees changes made since a status was seen", func() {
+		stat, err := Git.Status(repo, tempdir)
+		Expect(err).To(BeNil())
+		Expect(stat.Staged || stat.Unstaged || stat.Untracked).To(BeFalse())
+
+		Expect(ioutil.WriteFile(filepath.Join(tempdir, "new"), []byte("x"), 0644)).To(BeNil())
+		Expect(Git.Dirty(repo, tempdir, stat)).To(BeNil())
+		Expect(stat.Untracked).To(BeTrue())
+
+		git("add", "new")
+		Expect(Git.Dirty(repo, tempdir, stat)).To(BeNil())
+		Expect(stat.Staged).To(BeTrue())
+		Expect(stat.Untracked).To(BeFalse())
+	})
+
+	It("tells unstaged changes from staged ones", func() {
+		Expect(ioutil.WriteFile(filepath.Join(tempdir, "file"), []byte("x"), 0644)).To(BeNil())
+		git("add", "file")
+		git("-c", "user.name=jig", "-c", "user.email=jig@example.com", "commit", "-q", "-m", "file")
+		Expect(ioutil.WriteFile(filepath.Join(tempdir, "file"), []byte("y"), 0644)).To(BeNil())
+		stat, err := Git.Status(repo, tempdir)
+		Expect(err).To(BeNil())
+		Expect(stat.Unstaged).To(BeTrue())
+		Expect(stat.Staged).To(BeFalse())
+	})
+
+	It("keeps merged branches that are checked out in another worktree", func() {
+		git("branch", "topic")
+		git("branch", "done")
+		git("worktree", "add", "-q", filepath.Join(tempdir, "wt"), "topic")
+		out, err := exec.Command("git", "-C", tempdir, "symbolic-ref", "--short", "HEAD").Output()
+		Expect(err).To(BeNil())
+		r := &config.Repo{Repo: repo.Repo, Ref: strings.TrimSpace(string(out))}
+
+		result := Git.GC(r, tempdir, GCOptions{DryRun: true})
+		Expect(result.Err).To(BeNil())
+		Expect(result.DeletedBranches).To(Equal([]string{"done"}))
+	})
+
+	Context("bundling only what changed", func() {
+
+		var (
+			bundle string
+			since  []string
+		)
+
+		BeforeEach(func() {
+			git("branch", "topic")
+			refs, err := Git.Refs(repo, tempdir)
+			Expect(err).To(BeNil())
+			since = nil
+			for _, sha := range refs {
+				since = append(since, sha)
+			}
+			bundle = filepath.Join(tempdir, "out", "repo.bundle")
+		})
+
+		It("writes nothing when only a tag was added", func() {
+			git("tag", "v1")
+			_, err := Git.CreateBundle(repo, tempdir, bundle, since)
+			Expect(err).To(Equal(ErrEmptyBundle))
+			_, err = os.Stat(bundle)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("writes nothing when only a branch was deleted", func() {
+			git("branch", "-D", "topic")
+			_, err := Git.CreateBundle(repo, tempdir, bundle, since)
+			Expect(err).To(Equal(ErrEmptyBundle))
+		})
+
+		It("still bundles a new annotated tag", func() {
+			git("-c", "user.name=jig", "-c", "user.email=jig@example.com", "tag", "-a", "-m", "v1", "v1")
+			progress, err := Git.CreateBundle(repo, tempdir, bundle, since)
+			Expect(err).To(BeNil())
+			for p := range progress {
+				Expect(p.Err).To(BeNil())
+			}
+			_, err = os.Stat(bundle)
+			Expect(err).To(BeNil())
+		})
+
+		It("restores just the refs of a repository that exists", func() {
+			clone := filepath.Join(tempdir, "clone")
+			git("clone", "-q", tempdir, clone)
+			git("tag", "v1")
+			git("branch", "next")
+			refs, err := Git.Refs(repo, tempdir)
+			Expect(err).To(BeNil())
+			// A clone keeps the branches it was bundled with as origin's
+			refs["refs/remotes/origin/next"] = refs["refs/heads/next"]
+
+			progress, err := Git.RestoreBundle(repo, clone, "", refs)
+			Expect(err).To(BeNil())
+			for p := range progress {
+				Expect(p.Err).To(BeNil())
+			}
+			for _, ref := range []string{"refs/tags/v1", "refs/remotes/origin/next"} {
+				out, err := exec.Command("git", "-C", clone, "rev-parse", ref).CombinedOutput()
+				Expect(err).To(BeNil(), string(out))
+				Expect(strings.TrimSpace(string(out))).To(Equal(refs[ref]))
+			}
+		})
+
+	})
+
+})
@@ -0,0 +1,346 @@
+package vcs
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iancmcc/jig/utils"
+)
+
+// Phase is a stage of a git operation that reports progress
+type Phase int
+
+const (
+	// PhaseUnknown is any progress git reports that isn't accounted for
+	PhaseUnknown Phase = iota
+	// PhaseCounting is the remote enumerating and counting objects to send
+	PhaseCounting
+	// PhaseCompressing is the remote compressing objects to send
+	PhaseCompressing
+	// PhaseReceiving is objects being downloaded
+	PhaseReceiving
+	// PhaseResolving is downloaded deltas being resolved and checked
+	PhaseResolving
+	// PhaseCheckout is files being written to the working tree
+	PhaseCheckout
+)
+
+var (
+	phaseNames = map[Phase]string{
+		PhaseUnknown:     "working",
+		PhaseCounting:    "counting",
+		PhaseCompressing: "compressing",
+		PhaseReceiving:   "receiving",
+		PhaseResolving:   "resolving",
+		PhaseCheckout:    "checkout",
+	}
+
+	phaseMessages = map[string]Phase{
+		"Enumerating objects": PhaseCounting,
+		"Counting objects":    PhaseCounting,
+		"Compressing objects": PhaseCompressing,
+		"Receiving objects":   PhaseReceiving,
+		"Unpacking objects":   PhaseReceiving,
+		"Resolving deltas":    PhaseResolving,
+		"Checking objects":    PhaseResolving,
+		"Updating files":      PhaseCheckout,
+		"Checking out files":  PhaseCheckout,
+	}
+
+	// PhaseWeights is the share of the work on a repository attributed to
+	// each phase. Downloading dominates for most repositories.
+	PhaseWeights = map[Phase]float64{
+		PhaseCounting:    0.05,
+		PhaseCompressing: 0.10,
+		PhaseReceiving:   0.60,
+		PhaseResolving:   0.15,
+		PhaseCheckout:    0.10,
+	}
+
+	// throughputWindow is how far back throughput is measured
+	throughputWindow = 5 * time.Second
+)
+
+// ParsePhase identifies the phase a git progress message belongs to
+func ParsePhase(message string) Phase {
+	return phaseMessages[strings.TrimSpace(message)]
+}
+
+func (p Phase) String() string {
+	return phaseNames[p]
+}
+
+// Progress is a unit of progress reported by VCS
+type Progress struct {
+	Repo    string
+	IsBegin bool
+	IsEnd   bool
+	Message string
+	Phase   Phase
+	Current int
+	Total   int
+	// Bytes is the amount of data transferred so far in this phase, if git
+	// reports it
+	Bytes int64
+	// Err is set on the last Progress of an operation that failed
+	Err error
+}
+
+// Task is the progress of an operation on a single repository. The operation
+// is finished when Progress is closed.
+type Task struct {
+	Repo     string
+	Progress <-chan Progress
+}
+
+// Failed is the final Progress of an operation on repo that failed with err
+func Failed(repo string, err error) Progress {
+	return Progress{Repo: repo, Err: err}
+}
+
+// NewTask names the progress of an operation on a repository by the path the
+// repository is checked out to
+func NewTask(repo string, progress <-chan Progress) Task {
+	short, err := utils.RepoToPath(repo)
+	if err != nil {
+		short = repo
+	}
+	return Task{short, progress}
+}
+
+// RepoState is the progress of the operation on a single repository
+type RepoState struct {
+	Repo     string
+	Phase    Phase
+	Message  string
+	Current  int
+	Total    int
+	Fraction float64
+	// Bytes is the amount of data transferred for this repository
+	Bytes    int64
+	Done     bool
+	Started  time.Time
+	Finished time.Time
+	// Err is the first error the operation reported, if any
+	Err error
+}
+
+// Overall is the combined progress of several tasks
+type Overall struct {
+	// Fraction is the overall completion, from 0 to 1
+	Fraction float64
+	// Repos holds the state of each task, in the order the tasks were given
+	Repos  []RepoState
+	Active int
+	Done   int
+	// Failed is the number of finished repositories that reported an error
+	Failed int
+	// Throughput is the recent download rate, in bytes per second
+	Throughput float64
+	Elapsed    time.Duration
+	// ETA is the estimated time remaining, or 0 if it can't be estimated yet
+	ETA time.Duration
+}
+
+type sample struct {
+	at    time.Time
+	bytes int64
+}
+
+type repoTracker struct {
+	state RepoState
+	// bytes is data transferred by phases that have finished
+	bytes int64
+}
+
+// Tracker accounts for the progress of several tasks, weighting the phases
+// of each so that overall completion only moves forward
+type Tracker struct {
+	now     func() time.Time
+	start   time.Time
+	order   []*repoTracker
+	repos   map[string]*repoTracker
+	samples []sample
+}
+
+// NewTracker creates a Tracker for the named repositories
+func NewTracker(repos ...string) *Tracker {
+	return NewTrackerWithClock(time.Now, repos...)
+}
+
+// NewTrackerWithClock creates a Tracker that reads the time from now
+func NewTrackerWithClock(now func() time.Time, repos ...string) *Tracker {
+	t := &Tracker{
+		now:   now,
+		start: now(),
+		repos: map[string]*repoTracker{},
+	}
+	for _, repo := range repos {
+		rt := &repoTracker{state: RepoState{Repo: repo, Started: t.start}}
+		t.order = append(t.order, rt)
+		t.repos[repo] = rt
+	}
+	return t
+}
+
+// repoFraction is the completion of a repository in phase, given the
+// completion of that phase. Earlier phases count as complete whether or not
+// git reported them, since small transfers skip some.
+func repoFraction(phase Phase, phaseFraction float64) float64 {
+	var f float64
+	for p := PhaseCounting; p < phase; p++ {
+		f += PhaseWeights[p]
+	}
+	return f + PhaseWeights[phase]*phaseFraction
+}
+
+// Update records progress for a repository
+func (t *Tracker) Update(repo string, p Progress) {
+	rt, ok := t.repos[repo]
+	if !ok || rt.state.Done {
+		return
+	}
+	s := &rt.state
+	if p.Err != nil {
+		if s.Err == nil {
+			s.Err = p.Err
+		}
+		return
+	}
+	s.Phase = p.Phase
+	s.Message = p.Message
+	s.Current = p.Current
+	s.Total = p.Total
+	if p.Bytes > 0 {
+		s.Bytes = rt.bytes + p.Bytes
+	}
+	if p.IsEnd {
+		// Later git commands report their transfers from zero again
+		rt.bytes = s.Bytes
+	}
+	var phaseFraction float64
+	switch {
+	case p.IsEnd:
+		phaseFraction = 1
+	case p.Total > 0:
+		phaseFraction = float64(p.Current) / float64(p.Total)
+	}
+	// Operations made of several git commands repeat phases, so never
+	// let a repository go backwards
+	if f := repoFraction(p.Phase, phaseFraction); f > s.Fraction {
+		s.Fraction = f
+	}
+	t.sample()
+}
+
+// Finish records that the operation on a repository has finished
+func (t *Tracker) Finish(repo string) {
+	rt, ok := t.repos[repo]
+	if !ok || rt.state.Done {
+		return
+	}
+	rt.state.Done = true
+	rt.state.Fraction = 1
+	rt.state.Finished = t.now()
+	t.sample()
+}
+
+func (t *Tracker) totalBytes() int64 {
+	var total int64
+	for _, rt := range t.order {
+		total += rt.state.Bytes
+	}
+	return total
+}
+
+func (t *Tracker) sample() {
+	now := t.now()
+	t.samples = append(t.samples, sample{now, t.totalBytes()})
+	var i int
+	for i < len(t.samples)-1 && now.Sub(t.samples[i].at) > throughputWindow {
+		i++
+	}
+	t.samples = t.samples[i:]
+}
+
+// Snapshot returns the combined progress so far
+func (t *Tracker) Snapshot() Overall {
+	now := t.now()
+	o := Overall{
+		Repos:   make([]RepoState, 0, len(t.order)),
+		Elapsed: now.Sub(t.start),
+	}
+	for _, rt := range t.order {
+		o.Repos = append(o.Repos, rt.state)
+		o.Fraction += rt.state.Fraction
+		if rt.state.Done {
+			o.Done++
+			if rt.state.Err != nil {
+				o.Failed++
+			}
+		} else {
+			o.Active++
+		}
+	}
+	if len(t.order) > 0 {
+		o.Fraction /= float64(len(t.order))
+	} else {
+		o.Fraction = 1
+	}
+	if len(t.samples) > 1 {
+		first, last := t.samples[0], t.samples[len(t.samples)-1]
+		if dt := last.at.Sub(first.at).Seconds(); dt > 0 {
+			o.Throughput = float64(last.bytes-first.bytes) / dt
+		}
+	}
+	if o.Fraction > 0 && o.Fraction < 1 {
+		o.ETA = time.Duration(float64(o.Elapsed) * (1 - o.Fraction) / o.Fraction)
+	}
+	return o
+}
+
+// CombinedProgress combines the progress from multiple tasks into a single
+// stream that reports on overall progress after every update
+func CombinedProgress(tasks ...Task) <-chan Overall {
+	type update struct {
+		repo     string
+		progress Progress
+		done     bool
+	}
+	updates := make(chan update)
+	resultchan := make(chan Overall)
+
+	repos := []string{}
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		repos = append(repos, task.Repo)
+		wg.Add(1)
+		go func(task Task) {
+			defer wg.Done()
+			for prog := range task.Progress {
+				updates <- update{repo: task.Repo, progress: prog}
+			}
+			updates <- update{repo: task.Repo, done: true}
+		}(task)
+	}
+	go func() {
+		wg.Wait()
+		close(updates)
+	}()
+
+	go func() {
+		defer close(resultchan)
+		tracker := NewTracker(repos...)
+		for u := range updates {
+			if u.done {
+				tracker.Finish(u.repo)
+			} else {
+				tracker.Update(u.repo, u.progress)
+			}
+			resultchan <- tracker.Snapshot()
+		}
+	}()
+
+	return resultchan
+}
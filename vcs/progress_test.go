@@ -0,0 +1,180 @@
+package vcs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/iancmcc/jig/vcs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// transcript replays git stderr recorded in testdata
+func transcript(name string) []Progress {
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	var progs []Progress
+	for p := range ParseProgress("example.com/owner/repo", f) {
+		progs = append(progs, p)
+	}
+	return progs
+}
+
+var _ = Describe("ParseProgress", func() {
+
+	It("identifies the phases of a clone", func() {
+		var phases []Phase
+		for _, p := range transcript("clone.stderr") {
+			if len(phases) == 0 || phases[len(phases)-1] != p.Phase {
+				phases = append(phases, p.Phase)
+			}
+		}
+		Ω(phases).Should(Equal([]Phase{
+			PhaseCounting,
+			PhaseCompressing,
+			PhaseReceiving,
+			PhaseResolving,
+			PhaseCheckout,
+		}))
+	})
+
+	It("reads the amount of data received", func() {
+		var bytes int64
+		for _, p := range transcript("clone.stderr") {
+			if p.Phase == PhaseReceiving && p.IsEnd {
+				bytes = p.Bytes
+			}
+		}
+		Ω(bytes).Should(BeNumerically("~", 6.38*(1<<20), 1<<10))
+	})
+
+	It("copes with short lines and the remote's terminal escapes", func() {
+		stderr := "\r\n\x1b\r\nK\n" +
+			"remote: Counting objects:  50% (1/2)\x1b[K\r" +
+			"remote: Counting objects: 100% (2/2), done.\x1b[K\n"
+		var progs []Progress
+		for p := range ParseProgress("example.com/owner/repo", strings.NewReader(stderr)) {
+			progs = append(progs, p)
+		}
+		Ω(progs).ShouldNot(BeEmpty())
+		last := progs[len(progs)-1]
+		Ω(last.Phase).Should(Equal(PhaseCounting))
+		Ω(last.IsEnd).Should(BeTrue())
+	})
+
+})
+
+var _ = Describe("Tracker", func() {
+
+	var (
+		now     time.Time
+		tracker *Tracker
+	)
+
+	clock := func() time.Time {
+		return now
+	}
+
+	// replay feeds a transcript to the tracker, advancing the clock by step
+	// for each line, and returns the overall completion after each line
+	replay := func(repo, name string, step time.Duration) []float64 {
+		var fractions []float64
+		for _, p := range transcript(name) {
+			now = now.Add(step)
+			tracker.Update(repo, p)
+			fractions = append(fractions, tracker.Snapshot().Fraction)
+		}
+		return fractions
+	}
+
+	BeforeEach(func() {
+		now = time.Unix(0, 0)
+		tracker = NewTrackerWithClock(clock, "a", "b")
+	})
+
+	It("never goes backwards when the phase changes", func() {
+		fractions := replay("a", "clone.stderr", 10*time.Millisecond)
+		fractions = append(fractions, replay("b", "fetch.stderr", 10*time.Millisecond)...)
+		for i := 1; i < len(fractions); i++ {
+			Ω(fractions[i]).Should(BeNumerically(">=", fractions[i-1]))
+		}
+	})
+
+	It("weights each repository equally", func() {
+		replay("a", "clone.stderr", 10*time.Millisecond)
+		tracker.Finish("a")
+		o := tracker.Snapshot()
+		Ω(o.Fraction).Should(BeNumerically("~", 0.5, 0.001))
+		Ω(o.Done).Should(Equal(1))
+		Ω(o.Active).Should(Equal(1))
+
+		tracker.Finish("b")
+		Ω(tracker.Snapshot().Fraction).Should(Equal(1.0))
+	})
+
+	It("counts phases git skipped as complete", func() {
+		replay("a", "checkout.stderr", 10*time.Millisecond)
+		state := tracker.Snapshot().Repos[0]
+		Ω(state.Phase).Should(Equal(PhaseCheckout))
+		Ω(state.Fraction).Should(BeNumerically("~", 1.0, 0.001))
+	})
+
+	It("reports per-repository state in task order", func() {
+		replay("b", "fetch.stderr", 10*time.Millisecond)
+		o := tracker.Snapshot()
+		Ω(o.Repos).Should(HaveLen(2))
+		Ω(o.Repos[0].Repo).Should(Equal("a"))
+		Ω(o.Repos[0].Fraction).Should(Equal(0.0))
+		Ω(o.Repos[1].Repo).Should(Equal("b"))
+		Ω(o.Repos[1].Phase).Should(Equal(PhaseResolving))
+	})
+
+	It("estimates throughput and time remaining", func() {
+		progs := transcript("clone.stderr")
+		var o Overall
+		for _, p := range progs {
+			now = now.Add(10 * time.Millisecond)
+			tracker.Update("a", p)
+			if p.Phase == PhaseReceiving && p.IsEnd {
+				o = tracker.Snapshot()
+				break
+			}
+		}
+		Ω(o.Throughput).Should(BeNumerically(">", 0))
+		Ω(o.ETA).Should(BeNumerically(">", 0))
+		// Half the repositories haven't started, so at least as long again
+		Ω(o.ETA).Should(BeNumerically(">=", o.Elapsed))
+	})
+
+	It("has no estimate before anything happens", func() {
+		o := tracker.Snapshot()
+		Ω(o.Fraction).Should(Equal(0.0))
+		Ω(o.ETA).Should(Equal(time.Duration(0)))
+	})
+
+	It("keeps the first error a repository reports", func() {
+		replay("a", "fetch.stderr", 10*time.Millisecond)
+		before := tracker.Snapshot().Repos[0]
+		tracker.Update("a", Failed("a", errors.New("first")))
+		tracker.Update("a", Failed("a", errors.New("second")))
+		tracker.Finish("a")
+		o := tracker.Snapshot()
+		Ω(o.Repos[0].Err).Should(MatchError("first"))
+		Ω(o.Repos[0].Message).Should(Equal(before.Message))
+		Ω(o.Failed).Should(Equal(1))
+		Ω(o.Done).Should(Equal(1))
+	})
+
+	It("ignores progress for unknown repositories", func() {
+		tracker.Update("c", Progress{Phase: PhaseReceiving, Current: 1, Total: 1, IsEnd: true})
+		Ω(tracker.Snapshot().Fraction).Should(Equal(0.0))
+	})
+
+})
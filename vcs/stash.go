@@ -0,0 +1,78 @@
+package vcs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+)
+
+// ErrStashConflict is returned when a stash can't be applied cleanly
+var ErrStashConflict = errors.New("conflicts applying stash")
+
+// StashSave stashes the changes in the repository in dir, returning the
+// stash commit, or "" if there was nothing to stash
+func (g *gitVCS) StashSave(r *config.Repo, dir, message string, untracked bool) (string, error) {
+	status := []string{"status", "--porcelain"}
+	if !untracked {
+		status = append(status, "--untracked-files=no")
+	}
+	changes, err := g.runNoProgress(r.Repo, dir, status...)
+	if err != nil {
+		return "", err
+	}
+	if len(bytes.TrimSpace(changes)) == 0 {
+		return "", nil
+	}
+	args := []string{"stash", "push", "--message", message}
+	if untracked {
+		args = append(args, "--include-untracked")
+	}
+	logrus.WithField("repo", r.Repo).Debug("Stashing changes")
+	if err := runWithOutput(dir, args...); err != nil {
+		return "", err
+	}
+	commit, err := g.runNoProgress(r.Repo, dir, "rev-parse", "refs/stash")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(commit)), nil
+}
+
+// StashPop applies the stash commit to the repository in dir and drops it.
+// If the stash doesn't apply cleanly it is kept, and the error wraps
+// ErrStashConflict.
+func (g *gitVCS) StashPop(r *config.Repo, dir, commit string) error {
+	logrus.WithField("repo", r.Repo).Debug("Applying stash")
+	data, err := rawGitRun(dir, "stash", "apply", commit)
+	if err != nil {
+		msg := string(bytes.TrimSpace(data))
+		if strings.Contains(msg, "CONFLICT") {
+			return fmt.Errorf("%w: %s", ErrStashConflict, msg)
+		}
+		if msg == "" {
+			return err
+		}
+		return errors.New(msg)
+	}
+	return g.StashDrop(r, dir, commit)
+}
+
+// StashDrop drops the stash commit from the repository in dir, if it's still
+// there
+func (g *gitVCS) StashDrop(r *config.Repo, dir, commit string) error {
+	// Stashes pushed since have moved this one down the list
+	list, err := g.runNoProgress(r.Repo, dir, "stash", "list", "--format=%H")
+	if err != nil {
+		return err
+	}
+	for i, line := range strings.Split(string(list), "\n") {
+		if strings.TrimSpace(line) == commit {
+			return runWithOutput(dir, "stash", "drop", "--quiet", fmt.Sprintf("stash@{%d}", i))
+		}
+	}
+	return nil
+}
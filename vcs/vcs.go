@@ -1,6 +1,7 @@
 package vcs
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 
@@ -8,12 +9,53 @@ import (
 	"github.com/iancmcc/jig/utils"
 )
 
+var (
+	// ErrOffline is returned for operations that need the network when
+	// working offline
+	ErrOffline = errors.New("working offline")
+	// ErrNotLocal is returned when working offline and a ref would have to
+	// be fetched
+	ErrNotLocal = errors.New("not available locally")
+)
+
 // VCS represents a version control system
 type VCS interface {
-	Clone(r *config.Repo, dir string, attemptShallow bool) (<-chan Progress, error)
+	Clone(r *config.Repo, dir string, opts CloneOptions) (<-chan Progress, error)
 	Pull(r *config.Repo, dir string) (<-chan Progress, error)
+	// Fetch updates remote-tracking refs without touching the working tree
+	Fetch(r *config.Repo, dir string) (<-chan Progress, error)
 	Checkout(r *config.Repo, dir string) error
 	Status(r *config.Repo, dir string) (*Status, error)
+	// Deepen fetches history missing from a shallow or partial clone. A depth
+	// of 0 converts the clone into a full one.
+	Deepen(r *config.Repo, dir string, depth int, disableSparse bool) (<-chan Progress, error)
+}
+
+// CloneOptions controls how much of a repository a clone fetches. Filter and
+// Sparse are defaults for repositories that don't set their own.
+type CloneOptions struct {
+	Shallow bool
+	Filter  string
+	Sparse  []string
+}
+
+func (o CloneOptions) filter(r *config.Repo) string {
+	if r.Filter != "" {
+		return r.Filter
+	}
+	return o.Filter
+}
+
+func (o CloneOptions) sparse(r *config.Repo) []string {
+	if len(r.Sparse) > 0 {
+		return r.Sparse
+	}
+	return o.Sparse
+}
+
+// Shape describes which parts of a repository are missing locally
+type Shape struct {
+	Shallow, Partial, Sparse bool
 }
 
 // Status is a function
@@ -22,10 +64,16 @@ type Status struct {
 	OrigRef                     string
 	Staged, Unstaged, Untracked bool
 	Branch                      string
+	// URL is where the repository is cloned and fetched from
+	URL string
+	// Ahead and Behind count the commits the current branch and its upstream
+	// have that the other doesn't
+	Ahead, Behind int
+	Shape
 }
 
 // ApplyRepoConfig is a function
-func ApplyRepoConfig(root string, vcs VCS, repo *config.Repo, attemptShallow bool) (<-chan Progress, error) {
+func ApplyRepoConfig(root string, vcs VCS, repo *config.Repo, opts CloneOptions) (<-chan Progress, error) {
 	dir, err := utils.RepoToPath(repo.Repo)
 	if err != nil {
 		return nil, err
@@ -38,28 +86,32 @@ func ApplyRepoConfig(root string, vcs VCS, repo *config.Repo, attemptShallow boo
 
 	out := make(chan Progress)
 
-	go func(dir string) error {
+	go func(dir string) {
 		defer close(out)
-		if _, err := os.Stat(dir); err != nil {
+		var (
+			progress <-chan Progress
+			err      error
+		)
+		if _, serr := os.Stat(dir); serr != nil {
 			// Directory doesn't exist
-			clonechan, err := vcs.Clone(repo, dir, attemptShallow)
-			if err != nil {
-				return err
-			}
-			for p := range clonechan {
-				out <- p
-			}
+			progress, err = vcs.Clone(repo, dir, opts)
 		} else {
-			pullchan, err := vcs.Pull(repo, dir)
-			if err != nil {
-				return err
-			}
-			for p := range pullchan {
-				out <- p
-			}
+			progress, err = vcs.Pull(repo, dir)
+		}
+		if err != nil {
+			out <- Failed(repo.Repo, err)
+			return
+		}
+		for p := range progress {
+			out <- p
+		}
+		if _, err := os.Stat(dir); err != nil {
+			// The clone failed, and has already said so
+			return
+		}
+		if err := vcs.Checkout(repo, dir); err != nil {
+			out <- Failed(repo.Repo, err)
 		}
-		vcs.Checkout(repo, dir)
-		return nil
 	}(dir)
 
 	return out, nil
@@ -0,0 +1,80 @@
+package vcs
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/iancmcc/jig/config"
+)
+
+// AddWorktree checks out branch in a new worktree of the repository in dir.
+// An existing local or remote branch is reused; otherwise the branch is
+// created from the repository's manifest ref.
+func (g *gitVCS) AddWorktree(r *config.Repo, dir, path, branch string) error {
+	if err := prepareDir(path); err != nil {
+		return err
+	}
+	var args []string
+	switch {
+	case g.hasRef(r, dir, "refs/heads/"+branch):
+		args = []string{"worktree", "add", path, branch}
+	case g.hasRef(r, dir, "refs/remotes/origin/"+branch):
+		args = []string{"worktree", "add", "--track", "-b", branch, path, "origin/" + branch}
+	default:
+		args = []string{"worktree", "add", "-b", branch, path, r.Ref}
+	}
+	logrus.WithFields(logrus.Fields{
+		"repo":   r.Repo,
+		"branch": branch,
+	}).Debug("Adding git worktree")
+	return runWithOutput(dir, args...)
+}
+
+// RemoveWorktree removes a worktree of the repository in dir
+func (g *gitVCS) RemoveWorktree(r *config.Repo, dir, path string, force bool) error {
+	args := []string{"worktree", "remove", path}
+	if force {
+		args = append(args, "--force")
+	}
+	if err := runWithOutput(dir, args...); err != nil {
+		return err
+	}
+	_, err := g.runNoProgress(r.Repo, dir, "worktree", "prune")
+	return err
+}
+
+// worktreeBranches returns the branches checked out in any worktree of the
+// repository in dir, including its main one
+func (g *gitVCS) worktreeBranches(r *config.Repo, dir string) ([]string, error) {
+	data, err := g.runNoProgress(r.Repo, dir, "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+	branches := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "branch refs/heads/") {
+			branches = append(branches, strings.TrimPrefix(line, "branch refs/heads/"))
+		}
+	}
+	return branches, nil
+}
+
+func (g *gitVCS) hasRef(r *config.Repo, dir, ref string) bool {
+	_, err := g.runNoProgress(r.Repo, dir, "show-ref", "--verify", "--quiet", ref)
+	return err == nil
+}
+
+// runWithOutput runs a git command, turning its output into the error if it
+// fails
+func runWithOutput(dir string, args ...string) error {
+	data, err := rawGitRun(dir, args...)
+	if err != nil {
+		if msg := bytes.TrimSpace(data); len(msg) > 0 {
+			return errors.New(string(msg))
+		}
+		return err
+	}
+	return nil
+}